@@ -0,0 +1,160 @@
+// Package bytesconv provides conversions between strings and byte slices
+// which do not copy, joins which allocate at most once and case conversions
+// of ASCII text.
+//
+// The conversions share memory between the string and the slice, so the
+// bytes must not be modified while the string is in use. They are built on
+// unsafe.String and unsafe.Slice from Go 1.20, and on the memory layout of
+// strings and slices before.
+package bytesconv
+
+// AppendJoin appends the elements of elems separated by sep to dst, and
+// returns the extended slice. It does not allocate if dst has room.
+func AppendJoin(dst []byte, elems []string, sep string) []byte {
+	for i, e := range elems {
+		if i > 0 {
+			dst = append(dst, sep...)
+		}
+		dst = append(dst, e...)
+	}
+	return dst
+}
+
+// Join concatenates the elements of elems separated by sep, like
+// strings.Join, with a single allocation which becomes the result.
+func Join(elems []string, sep string) string {
+	switch len(elems) {
+	case 0:
+		return ""
+	case 1:
+		return elems[0]
+	}
+	n := len(sep) * (len(elems) - 1)
+	for _, e := range elems {
+		n += len(e)
+	}
+	return BytesToString(AppendJoin(make([]byte, 0, n), elems, sep))
+}
+
+// JoinBytes concatenates the elements of elems separated by sep into a
+// string, with a single allocation which becomes the result.
+func JoinBytes(elems [][]byte, sep []byte) string {
+	if len(elems) == 0 {
+		return ""
+	}
+	n := len(sep) * (len(elems) - 1)
+	for _, e := range elems {
+		n += len(e)
+	}
+	b := make([]byte, 0, n)
+	for i, e := range elems {
+		if i > 0 {
+			b = append(b, sep...)
+		}
+		b = append(b, e...)
+	}
+	return BytesToString(b)
+}
+
+// ToLowerASCII returns s with the ASCII letters mapped to lower case, other
+// bytes are left unchanged. It returns s itself, without allocating, if s
+// has no upper case ASCII letter.
+func ToLowerASCII(s string) string {
+	i := 0
+	for i < len(s) && !isUpper(s[i]) {
+		i++
+	}
+	if i == len(s) {
+		return s
+	}
+	b := make([]byte, len(s))
+	copy(b, s[:i])
+	for ; i < len(s); i++ {
+		b[i] = toLower(s[i])
+	}
+	return BytesToString(b)
+}
+
+// ToUpperASCII returns s with the ASCII letters mapped to upper case, other
+// bytes are left unchanged. It returns s itself, without allocating, if s
+// has no lower case ASCII letter.
+func ToUpperASCII(s string) string {
+	i := 0
+	for i < len(s) && !isLower(s[i]) {
+		i++
+	}
+	if i == len(s) {
+		return s
+	}
+	b := make([]byte, len(s))
+	copy(b, s[:i])
+	for ; i < len(s); i++ {
+		b[i] = toUpper(s[i])
+	}
+	return BytesToString(b)
+}
+
+// AppendLowerASCII appends s with the ASCII letters mapped to lower case to
+// dst, and returns the extended slice.
+func AppendLowerASCII(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		dst = append(dst, toLower(s[i]))
+	}
+	return dst
+}
+
+// AppendUpperASCII appends s with the ASCII letters mapped to upper case to
+// dst, and returns the extended slice.
+func AppendUpperASCII(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		dst = append(dst, toUpper(s[i]))
+	}
+	return dst
+}
+
+// LowerASCII maps the ASCII letters of b to lower case in place.
+func LowerASCII(b []byte) {
+	for i, c := range b {
+		b[i] = toLower(c)
+	}
+}
+
+// UpperASCII maps the ASCII letters of b to upper case in place.
+func UpperASCII(b []byte) {
+	for i, c := range b {
+		b[i] = toUpper(c)
+	}
+}
+
+// EqualFoldASCII reports whether s and t are equal when ASCII letters are
+// compared regardless of case. Unlike strings.EqualFold, other bytes must be
+// equal.
+func EqualFoldASCII(s, t string) bool {
+	if len(s) != len(t) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if toLower(s[i]) != toLower(t[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isUpper(c byte) bool { return 'A' <= c && c <= 'Z' }
+
+func isLower(c byte) bool { return 'a' <= c && c <= 'z' }
+
+func toLower(c byte) byte {
+	if isUpper(c) {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func toUpper(c byte) byte {
+	if isLower(c) {
+		return c - ('a' - 'A')
+	}
+	return c
+}
@@ -0,0 +1,147 @@
+package bytesconv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConversions(t *testing.T) {
+	s := "hello, world"
+	b := StringToBytes(s)
+	if string(b) != s || len(b) != len(s) || cap(b) != len(s) {
+		t.Fatalf("StringToBytes(%q) = %q, cap %d", s, b, cap(b))
+	}
+	if &b[0] != &StringToBytes(s)[0] {
+		t.Fatal("StringToBytes copied")
+	}
+	if BytesToString(b) != s {
+		t.Fatalf("BytesToString(%q) = %q", b, BytesToString(b))
+	}
+	if len(StringToBytes("")) != 0 || BytesToString(nil) != "" {
+		t.Fatal("empty conversions are not empty")
+	}
+
+	// The string shares the bytes of the slice.
+	buf := []byte("abc")
+	str := BytesToString(buf)
+	buf[0] = 'x'
+	if str != "xbc" {
+		t.Fatalf("BytesToString copied: %q", str)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	for _, elems := range [][]string{nil, {}, {"a"}, {"a", ""}, {"a", "bc", "def"}} {
+		for _, sep := range []string{"", ", "} {
+			want := strings.Join(elems, sep)
+			if got := Join(elems, sep); got != want {
+				t.Errorf("Join(%q, %q) = %q, want %q", elems, sep, got, want)
+			}
+			if got := string(AppendJoin([]byte("x"), elems, sep)); got != "x"+want {
+				t.Errorf("AppendJoin(x, %q, %q) = %q, want %q", elems, sep, got, "x"+want)
+			}
+			var bs [][]byte
+			for _, e := range elems {
+				bs = append(bs, []byte(e))
+			}
+			if got := JoinBytes(bs, []byte(sep)); got != want {
+				t.Errorf("JoinBytes(%q, %q) = %q, want %q", elems, sep, got, want)
+			}
+		}
+	}
+}
+
+func TestCase(t *testing.T) {
+	tests := []struct{ in, lower, upper string }{
+		{"", "", ""},
+		{"hello", "hello", "HELLO"},
+		{"Content-Type", "content-type", "CONTENT-TYPE"},
+		{"ÄbC-1", "Äbc-1", "ÄBC-1"},
+	}
+	for _, tt := range tests {
+		if got := ToLowerASCII(tt.in); got != tt.lower {
+			t.Errorf("ToLowerASCII(%q) = %q, want %q", tt.in, got, tt.lower)
+		}
+		if got := ToUpperASCII(tt.in); got != tt.upper {
+			t.Errorf("ToUpperASCII(%q) = %q, want %q", tt.in, got, tt.upper)
+		}
+		if got := string(AppendLowerASCII(nil, tt.in)); got != tt.lower {
+			t.Errorf("AppendLowerASCII(%q) = %q, want %q", tt.in, got, tt.lower)
+		}
+		if got := string(AppendUpperASCII(nil, tt.in)); got != tt.upper {
+			t.Errorf("AppendUpperASCII(%q) = %q, want %q", tt.in, got, tt.upper)
+		}
+		b := []byte(tt.in)
+		LowerASCII(b)
+		if string(b) != tt.lower {
+			t.Errorf("LowerASCII(%q) = %q, want %q", tt.in, b, tt.lower)
+		}
+		UpperASCII(b)
+		if string(b) != tt.upper {
+			t.Errorf("UpperASCII(%q) = %q, want %q", tt.in, b, tt.upper)
+		}
+		if !EqualFoldASCII(tt.lower, tt.upper) || !EqualFoldASCII(tt.in, tt.upper) {
+			t.Errorf("EqualFoldASCII(%q, %q) = false", tt.lower, tt.upper)
+		}
+	}
+	if EqualFoldASCII("a", "b") || EqualFoldASCII("a", "ab") || EqualFoldASCII("ä", "Ä") {
+		t.Error("EqualFoldASCII of different strings = true")
+	}
+}
+
+func TestAllocs(t *testing.T) {
+	s, b := "Hello, World", []byte("Hello, World")
+	elems := []string{"a", "bc", "def"}
+	buf := make([]byte, 0, 64)
+	tests := []struct {
+		name   string
+		allocs float64
+		f      func()
+	}{
+		{"StringToBytes", 0, func() { _ = StringToBytes(s) }},
+		{"BytesToString", 0, func() { sink = BytesToString(b) }},
+		{"AppendJoin", 0, func() { _ = AppendJoin(buf[:0], elems, ", ") }},
+		{"Join", 1, func() { sink = Join(elems, ", ") }},
+		{"JoinBytes", 1, func() { sink = JoinBytes([][]byte{b, b}, b) }},
+		{"ToLowerASCII lower", 0, func() { sink = ToLowerASCII("hello, world") }},
+		{"ToLowerASCII", 1, func() { sink = ToLowerASCII(s) }},
+		{"ToUpperASCII upper", 0, func() { sink = ToUpperASCII("HELLO, WORLD") }},
+		{"AppendLowerASCII", 0, func() { _ = AppendLowerASCII(buf[:0], s) }},
+		{"LowerASCII", 0, func() { LowerASCII(b) }},
+		{"EqualFoldASCII", 0, func() { _ = EqualFoldASCII(s, "hello, world") }},
+	}
+	for _, tt := range tests {
+		if allocs := testing.AllocsPerRun(100, tt.f); allocs != tt.allocs {
+			t.Errorf("%s allocates %v times, want %v", tt.name, allocs, tt.allocs)
+		}
+	}
+}
+
+var sink string
+
+func BenchmarkBytesToString(b *testing.B) {
+	buf := []byte("Content-Type")
+	b.Run("conversion", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sink = string(buf)
+		}
+	})
+	b.Run("BytesToString", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sink = BytesToString(buf)
+		}
+	})
+}
+
+func BenchmarkToLower(b *testing.B) {
+	b.Run("strings", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sink = strings.ToLower("Content-Type")
+		}
+	})
+	b.Run("ASCII", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sink = ToLowerASCII("Content-Type")
+		}
+	})
+}
@@ -0,0 +1,24 @@
+//go:build !go1.20
+// +build !go1.20
+
+package bytesconv
+
+import "unsafe"
+
+// StringToBytes returns the bytes of s without copying them. The bytes must
+// not be modified, since strings are immutable and may be in read-only
+// memory.
+func StringToBytes(s string) []byte {
+	// A string is laid out like the beginning of a slice, so a slice can be
+	// read from a string followed by its capacity.
+	return *(*[]byte)(unsafe.Pointer(&struct {
+		string
+		Cap int
+	}{s, len(s)}))
+}
+
+// BytesToString returns b as a string without copying it. b must not be
+// modified while the string is in use.
+func BytesToString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
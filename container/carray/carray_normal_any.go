@@ -3,10 +3,12 @@ package carray
 import (
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"sort"
 
 	"github.com/funbytes/modern-go/internal/rwmutex"
+	"github.com/funbytes/modern-go/mutex"
 )
 
 // Array is a golang array with rich features.
@@ -14,9 +16,16 @@ import (
 // when its initialization and cannot be changed then.
 type Array struct {
 	mu    *rwmutex.RWMutex
+	rand  *lockedRand
 	array []interface{}
 }
 
+// lockedRand guards a *rand.Rand, which is not safe for concurrent use.
+type lockedRand struct {
+	mu *mutex.Mutex
+	r  *rand.Rand
+}
+
 // New creates and returns an empty array.
 // The parameter <safe> is used to specify whether using array in concurrent-safety,
 // which is false in default.
@@ -56,6 +65,58 @@ func NewArrayFromCopy(array []interface{}, safe ...bool) *Array {
 	}
 }
 
+// NewWithRand creates and returns an empty array which draws its random numbers
+// from <r> instead of the global math/rand source, see SetRand.
+// The parameter <safe> is used to specify whether using array in concurrent-safety,
+// which is false in default.
+func NewWithRand(r *rand.Rand, safe ...bool) *Array {
+	return New(safe...).SetRand(r)
+}
+
+// SetRand sets the random source used by PopRand, PopRands, Rand, Rands,
+// RandWeighted and Shuffle. A seeded <r> makes these operations reproducible,
+// and a <r> created from crypto.NewRandSource makes them unpredictable.
+// If <r> is nil, the global math/rand source is used, which is the default.
+func (a *Array) SetRand(r *rand.Rand) *Array {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if r == nil {
+		a.rand = nil
+	} else {
+		a.rand = &lockedRand{mu: mutex.NewMutex(true), r: r}
+	}
+	return a
+}
+
+// SetLockName names the lock of array in the reports of the mutex debug
+// mode, see mutex.EnableDebug, which otherwise names it after the site
+// creating the array. It does nothing unless the array is concurrent-safe
+// and was created while the debug mode was enabled.
+func (a *Array) SetLockName(name string) *Array {
+	a.mu.SetName(name)
+	return a
+}
+
+// intn returns a random number in [0, n) from the random source of array.
+func (a *Array) intn(n int) int {
+	if a.rand == nil {
+		return rand.Intn(n)
+	}
+	a.rand.mu.Lock()
+	defer a.rand.mu.Unlock()
+	return a.rand.r.Intn(n)
+}
+
+// float64 returns a random number in [0.0, 1.0) from the random source of array.
+func (a *Array) float64() float64 {
+	if a.rand == nil {
+		return rand.Float64()
+	}
+	a.rand.mu.Lock()
+	defer a.rand.mu.Unlock()
+	return a.rand.r.Float64()
+}
+
 // Get returns the value by the specified index.
 // If the given <index> is out of range of the array, the <found> is false.
 func (a *Array) Get(index int) (value interface{}, found bool) {
@@ -111,10 +172,12 @@ func (a *Array) SortFunc(less func(v1, v2 interface{}) bool) *Array {
 }
 
 // InsertBefore inserts the <value> to the front of <index>.
+// The <index> may equal the length of array, which appends the <value>
+// to the end, so that inserting into an empty array at index 0 succeeds.
 func (a *Array) InsertBefore(index int, value interface{}) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	if index < 0 || index >= len(a.array) {
+	if index < 0 || index > len(a.array) {
 		return errors.New(fmt.Sprintf("index %d out of array range %d", index, len(a.array)))
 	}
 	rear := append([]interface{}{}, a.array[index:]...)
@@ -124,9 +187,14 @@ func (a *Array) InsertBefore(index int, value interface{}) error {
 }
 
 // InsertAfter inserts the <value> to the back of <index>.
+// If the array is empty, the <index> 0 is accepted and the <value> is appended.
 func (a *Array) InsertAfter(index int, value interface{}) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	if len(a.array) == 0 && index == 0 {
+		a.array = append(a.array, value)
+		return nil
+	}
 	if index < 0 || index >= len(a.array) {
 		return errors.New(fmt.Sprintf("index %d out of array range %d", index, len(a.array)))
 	}
@@ -170,8 +238,10 @@ func (a *Array) doRemoveWithoutLock(index int) (value interface{}, found bool) {
 // RemoveValue removes an item by value.
 // It returns true if value is found in the array, or else false if not found.
 func (a *Array) RemoveValue(value interface{}) bool {
-	if i := a.Search(value); i != -1 {
-		a.Remove(i)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if i := a.doSearchWithoutLock(value); i != -1 {
+		a.doRemoveWithoutLock(i)
 		return true
 	}
 	return false
@@ -199,7 +269,10 @@ func (a *Array) PushRight(value ...interface{}) *Array {
 func (a *Array) PopRand() (value interface{}, found bool) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	return a.doRemoveWithoutLock(rand.Intn(len(a.array)))
+	if len(a.array) == 0 {
+		return nil, false
+	}
+	return a.doRemoveWithoutLock(a.intn(len(a.array)))
 }
 
 // PopRands randomly pops and returns <size> items out of array.
@@ -214,7 +287,7 @@ func (a *Array) PopRands(size int) []interface{} {
 	}
 	array := make([]interface{}, size)
 	for i := 0; i < size; i++ {
-		array[i], _ = a.doRemoveWithoutLock(rand.Intn(len(a.array)))
+		array[i], _ = a.doRemoveWithoutLock(a.intn(len(a.array)))
 	}
 	return array
 }
@@ -254,11 +327,11 @@ func (a *Array) PopLefts(size int) []interface{} {
 		return nil
 	}
 	if size >= len(a.array) {
-		array := a.array
-		a.array = a.array[:0]
+		array := a.array[:len(a.array):len(a.array)]
+		a.array = a.array[len(a.array):]
 		return array
 	}
-	value := a.array[0:size]
+	value := a.array[0:size:size]
 	a.array = a.array[size:]
 	return value
 }
@@ -272,11 +345,14 @@ func (a *Array) PopRights(size int) []interface{} {
 	}
 	index := len(a.array) - size
 	if index <= 0 {
-		array := a.array
-		a.array = a.array[:0]
+		array := a.array[:len(a.array):len(a.array)]
+		a.array = a.array[len(a.array):]
 		return array
 	}
-	value := a.array[index:]
+	// The popped items are copied out, or else later appends would
+	// overwrite them in the shared underlying slice.
+	value := make([]interface{}, size)
+	copy(value, a.array[index:])
 	a.array = a.array[:index]
 	return value
 }
@@ -292,15 +368,19 @@ func (a *Array) Range(start int, end ...int) []interface{} {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 	offsetEnd := len(a.array)
-	if len(end) > 0 && end[0] < offsetEnd {
-		offsetEnd = end[0]
-	}
-	if start > offsetEnd {
-		return nil
+	if len(end) > 0 {
+		if end[0] < 0 {
+			offsetEnd += end[0]
+		} else if end[0] < offsetEnd {
+			offsetEnd = end[0]
+		}
 	}
 	if start < 0 {
 		start = 0
 	}
+	if start >= offsetEnd {
+		return nil
+	}
 	array := ([]interface{})(nil)
 	if a.mu.IsSafe() {
 		array = make([]interface{}, offsetEnd-start)
@@ -326,12 +406,15 @@ func (a *Array) Len() int {
 }
 
 // Clone returns a new array, which is a copy of current array.
+// The new array shares the random source of current array.
 func (a *Array) Clone() (newArray *Array) {
 	a.mu.RLock()
 	array := make([]interface{}, len(a.array))
 	copy(array, a.array)
+	newArray = NewArrayFrom(array, a.mu.IsSafe())
+	newArray.rand = a.rand
 	a.mu.RUnlock()
-	return NewArrayFrom(array, a.mu.IsSafe())
+	return newArray
 }
 
 // Clear deletes all items of current array.
@@ -354,6 +437,11 @@ func (a *Array) Contains(value interface{}) bool {
 func (a *Array) Search(value interface{}) int {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
+	return a.doSearchWithoutLock(value)
+}
+
+// doSearchWithoutLock searches array by <value> without lock.
+func (a *Array) doSearchWithoutLock(value interface{}) int {
 	if len(a.array) == 0 {
 		return -1
 	}
@@ -407,7 +495,7 @@ func (a *Array) Rand() (value interface{}, found bool) {
 	if len(a.array) == 0 {
 		return nil, false
 	}
-	return a.array[rand.Intn(len(a.array))], true
+	return a.array[a.intn(len(a.array))], true
 }
 
 // Rands randomly returns <size> items from array(no deleting).
@@ -419,17 +507,62 @@ func (a *Array) Rands(size int) []interface{} {
 	}
 	array := make([]interface{}, size)
 	for i := 0; i < size; i++ {
-		array[i] = a.array[rand.Intn(len(a.array))]
+		array[i] = a.array[a.intn(len(a.array))]
 	}
 	return array
 }
 
+// RandWeighted randomly returns one item from array(no deleting), where the
+// chance of each item is proportional to its weight given by <weight>.
+// Items with a non-positive, infinite or NaN weight are never returned, as
+// no finite chance can be derived from such a weight.
+// Note that if no item has a positive finite weight, the <found> is false.
+func (a *Array) RandWeighted(weight func(v interface{}) float64) (value interface{}, found bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	weights := make([]float64, len(a.array))
+	total := 0.0
+	for i, v := range a.array {
+		// The negated test also skips NaN.
+		if w := weight(v); w > 0 && !math.IsInf(w, 1) {
+			weights[i] = w
+			total += w
+		}
+	}
+	if math.IsInf(total, 1) {
+		// Huge finite weights overflowed, scale them down to sum finitely.
+		total = 0
+		for i := range weights {
+			weights[i] /= float64(len(weights))
+			total += weights[i]
+		}
+	}
+	if total <= 0 {
+		return nil, false
+	}
+	r := a.float64() * total
+	last := -1
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		if r < w {
+			return a.array[i], true
+		}
+		r -= w
+		last = i
+	}
+	// Rounding errors may leave a tiny remainder, which belongs to the last candidate.
+	return a.array[last], true
+}
+
 // Shuffle randomly shuffles the array.
 func (a *Array) Shuffle() *Array {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	for i, v := range rand.Perm(len(a.array)) {
-		a.array[i], a.array[v] = a.array[v], a.array[i]
+	for i := len(a.array) - 1; i > 0; i-- {
+		j := a.intn(i + 1)
+		a.array[i], a.array[j] = a.array[j], a.array[i]
 	}
 	return a
 }
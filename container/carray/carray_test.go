@@ -0,0 +1,424 @@
+package carray
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/funbytes/modern-go/crypto"
+	"github.com/funbytes/modern-go/mutex"
+)
+
+func TestEmptyArray(t *testing.T) {
+	a := New(true)
+	if v, ok := a.PopRand(); ok || v != nil {
+		t.Errorf("PopRand on empty array = (%v, %v), want (nil, false)", v, ok)
+	}
+	if v := a.PopRands(3); v != nil {
+		t.Errorf("PopRands on empty array = %v, want nil", v)
+	}
+	if v, ok := a.Rand(); ok || v != nil {
+		t.Errorf("Rand on empty array = (%v, %v), want (nil, false)", v, ok)
+	}
+	if v, ok := a.PopLeft(); ok || v != nil {
+		t.Errorf("PopLeft on empty array = (%v, %v), want (nil, false)", v, ok)
+	}
+	if v, ok := a.PopRight(); ok || v != nil {
+		t.Errorf("PopRight on empty array = (%v, %v), want (nil, false)", v, ok)
+	}
+	if a.RemoveValue(1) {
+		t.Error("RemoveValue on empty array should return false")
+	}
+	if v := a.Range(0, -1); v != nil {
+		t.Errorf("Range on empty array = %v, want nil", v)
+	}
+}
+
+func TestInsertIntoEmpty(t *testing.T) {
+	a := New()
+	if err := a.InsertBefore(0, 1); err != nil {
+		t.Fatalf("InsertBefore(0) on empty array: %v", err)
+	}
+	b := New()
+	if err := b.InsertAfter(0, 1); err != nil {
+		t.Fatalf("InsertAfter(0) on empty array: %v", err)
+	}
+	if err := a.InsertBefore(a.Len(), 2); err != nil {
+		t.Fatalf("InsertBefore(len): %v", err)
+	}
+	if got := a.Range(0); !reflect.DeepEqual(got, []interface{}{1, 2}) {
+		t.Errorf("array = %v, want [1 2]", got)
+	}
+	if err := a.InsertBefore(3, 0); err == nil {
+		t.Error("InsertBefore past the end should fail")
+	}
+	if err := a.InsertAfter(-1, 0); err == nil {
+		t.Error("InsertAfter with negative index should fail")
+	}
+}
+
+func TestPopDoesNotAlias(t *testing.T) {
+	a := NewArrayFrom([]interface{}{1, 2, 3, 4})
+	right := a.PopRights(2)
+	a.PushRight(5, 6)
+	if !reflect.DeepEqual(right, []interface{}{3, 4}) {
+		t.Errorf("PopRights result changed to %v after push", right)
+	}
+	left := a.PopLefts(10)
+	a.PushRight(7)
+	if !reflect.DeepEqual(left, []interface{}{1, 2, 5, 6}) {
+		t.Errorf("PopLefts result changed to %v after push", left)
+	}
+}
+
+func TestRangeNegativeEnd(t *testing.T) {
+	a := NewArrayFrom([]interface{}{1, 2, 3, 4})
+	if got := a.Range(1, -1); !reflect.DeepEqual(got, []interface{}{2, 3}) {
+		t.Errorf("Range(1, -1) = %v, want [2 3]", got)
+	}
+	if got := a.Range(0, -10); got != nil {
+		t.Errorf("Range(0, -10) = %v, want nil", got)
+	}
+	if got := a.Range(-3, 2); !reflect.DeepEqual(got, []interface{}{1, 2}) {
+		t.Errorf("Range(-3, 2) = %v, want [1 2]", got)
+	}
+}
+
+func TestRemoveValueConcurrent(t *testing.T) {
+	const n = 1000
+	a := New(true)
+	for i := 0; i < n; i++ {
+		a.Append(i)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < n; i += 4 {
+				if !a.RemoveValue(i) {
+					t.Errorf("RemoveValue(%d) = false", i)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if a.Len() != 0 {
+		t.Errorf("Len = %d after removing every value, want 0", a.Len())
+	}
+}
+
+// TestConcurrentMethods runs every method of a concurrent-safe Array from
+// several goroutines, and is meant to be run with the race detector.
+func TestConcurrentMethods(t *testing.T) {
+	a := New(true)
+	ops := []func(i int){
+		func(i int) { a.Get(i) },
+		func(i int) { a.Set(i, i) },
+		func(i int) { a.Replace([]interface{}{i}) },
+		func(i int) { a.SortFunc(func(v1, v2 interface{}) bool { return v1.(int) < v2.(int) }) },
+		func(i int) { a.InsertBefore(0, i) },
+		func(i int) { a.InsertAfter(0, i) },
+		func(i int) { a.Remove(i) },
+		func(i int) { a.RemoveValue(i) },
+		func(i int) { a.PushLeft(i) },
+		func(i int) { a.PushRight(i) },
+		func(i int) { a.PopRand() },
+		func(i int) { a.PopRands(2) },
+		func(i int) { a.PopLeft() },
+		func(i int) { a.PopRight() },
+		func(i int) { a.PopLefts(2) },
+		func(i int) { a.PopRights(2) },
+		func(i int) { a.Range(0, -1) },
+		func(i int) { a.Append(i, i) },
+		func(i int) { a.Len() },
+		func(i int) { a.Clone() },
+		func(i int) { a.Contains(i) },
+		func(i int) { a.Search(i) },
+		func(i int) { a.Unique() },
+		func(i int) { a.LockFunc(func(array []interface{}) {}) },
+		func(i int) { a.RLockFunc(func(array []interface{}) {}) },
+		func(i int) { a.Rand() },
+		func(i int) { a.Rands(2) },
+		func(i int) { a.RandWeighted(func(v interface{}) float64 { return 1 }) },
+		func(i int) { a.Shuffle() },
+		func(i int) { a.Reverse() },
+		func(i int) { a.CountValues() },
+		func(i int) { a.Iterator(func(k int, v interface{}) bool { return true }) },
+		func(i int) { a.IteratorDesc(func(k int, v interface{}) bool { return true }) },
+		func(i int) { a.Walk(func(value interface{}) interface{} { return value }) },
+		func(i int) { a.IsEmpty() },
+		func(i int) {
+			if i%50 == 0 {
+				a.Clear()
+			}
+		},
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				ops[(g+i)%len(ops)](i % 8)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestRandReproducible(t *testing.T) {
+	draw := func() []interface{} {
+		a := NewWithRand(rand.New(rand.NewSource(42)), true)
+		for i := 0; i < 20; i++ {
+			a.Append(i)
+		}
+		var out []interface{}
+		v, _ := a.Rand()
+		out = append(out, v)
+		out = append(out, a.Rands(3)...)
+		v, _ = a.PopRand()
+		out = append(out, v)
+		out = append(out, a.PopRands(3)...)
+		out = append(out, a.Shuffle().Range(0)...)
+		return out
+	}
+	if first, second := draw(), draw(); !reflect.DeepEqual(first, second) {
+		t.Errorf("draws with the same seed differ:\n%v\n%v", first, second)
+	}
+}
+
+func TestRandWeighted(t *testing.T) {
+	a := NewWithRand(rand.New(rand.NewSource(1)))
+	if _, ok := a.RandWeighted(func(v interface{}) float64 { return 1 }); ok {
+		t.Error("RandWeighted on empty array should not find an item")
+	}
+	a.Append("never", "rare", "often")
+	weights := map[interface{}]float64{"never": 0, "rare": 1, "often": 9}
+	counts := make(map[interface{}]int)
+	for i := 0; i < 10000; i++ {
+		v, ok := a.RandWeighted(func(v interface{}) float64 { return weights[v] })
+		if !ok {
+			t.Fatal("RandWeighted should find an item")
+		}
+		counts[v]++
+	}
+	if counts["never"] != 0 {
+		t.Errorf("item with zero weight was picked %d times", counts["never"])
+	}
+	if counts["often"] < 8500 || counts["often"] > 9500 {
+		t.Errorf("item with 90%% weight was picked %d of 10000 times", counts["often"])
+	}
+	if _, ok := a.RandWeighted(func(v interface{}) float64 { return -1 }); ok {
+		t.Error("RandWeighted with no positive weight should not find an item")
+	}
+
+	// Infinite and NaN weights are skipped, and huge finite weights do not
+	// overflow the total.
+	weights = map[interface{}]float64{"never": math.Inf(1), "rare": math.NaN(), "often": 1}
+	for i := 0; i < 100; i++ {
+		if v, _ := a.RandWeighted(func(v interface{}) float64 { return weights[v] }); v != "often" {
+			t.Fatalf("RandWeighted with non-finite weights picked %v", v)
+		}
+	}
+	weights = map[interface{}]float64{"never": 0, "rare": math.MaxFloat64, "often": math.MaxFloat64}
+	counts = make(map[interface{}]int)
+	for i := 0; i < 1000; i++ {
+		v, _ := a.RandWeighted(func(v interface{}) float64 { return weights[v] })
+		counts[v]++
+	}
+	if counts["rare"] < 400 || counts["often"] < 400 {
+		t.Errorf("items of equal huge weights were picked %v times", counts)
+	}
+}
+
+func TestCryptoRand(t *testing.T) {
+	a := NewWithRand(rand.New(crypto.NewRandSource()), true)
+	a.Append(1, 2, 3)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if _, ok := a.Rand(); !ok {
+					t.Error("Rand should find an item")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// FuzzArray applies a sequence of operations decoded from the input to an
+// Array and to a plain slice model, and checks that both stay in sync.
+func FuzzArray(f *testing.F) {
+	f.Add([]byte{0, 1, 2, 3})
+	f.Add([]byte{5, 0, 6, 0, 7, 9, 1, 1, 4, 2})
+	f.Add([]byte{3, 3, 3, 8, 8, 8, 2, 10, 11, 12})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		a := New()
+		var model []interface{}
+		for i := 0; i+1 < len(data); i += 2 {
+			op, arg := data[i]%13, int(data[i+1])%8
+			switch op {
+			case 0:
+				a.PushRight(arg)
+				model = append(model, arg)
+			case 1:
+				a.PushLeft(arg)
+				model = append([]interface{}{arg}, model...)
+			case 2:
+				v, ok := a.PopLeft()
+				if ok != (len(model) > 0) {
+					t.Fatalf("PopLeft found = %v with model %v", ok, model)
+				}
+				if ok {
+					if v != model[0] {
+						t.Fatalf("PopLeft = %v, want %v", v, model[0])
+					}
+					model = model[1:]
+				}
+			case 3:
+				v, ok := a.PopRight()
+				if ok != (len(model) > 0) {
+					t.Fatalf("PopRight found = %v with model %v", ok, model)
+				}
+				if ok {
+					if v != model[len(model)-1] {
+						t.Fatalf("PopRight = %v, want %v", v, model[len(model)-1])
+					}
+					model = model[:len(model)-1]
+				}
+			case 4:
+				_, ok := a.PopRand()
+				if ok != (len(model) > 0) {
+					t.Fatalf("PopRand found = %v with model %v", ok, model)
+				}
+				// The popped position is random, so resync the model.
+				model = append([]interface{}{}, a.Range(0)...)
+			case 5:
+				err := a.InsertBefore(arg, arg)
+				if (err == nil) != (arg <= len(model)) {
+					t.Fatalf("InsertBefore(%d) err = %v with model %v", arg, err, model)
+				}
+				if err == nil {
+					model = append(model[:arg], append([]interface{}{arg}, model[arg:]...)...)
+				}
+			case 6:
+				err := a.InsertAfter(arg, arg)
+				valid := arg < len(model) || (len(model) == 0 && arg == 0)
+				if (err == nil) != valid {
+					t.Fatalf("InsertAfter(%d) err = %v with model %v", arg, err, model)
+				}
+				if err == nil {
+					pos := arg + 1
+					if len(model) == 0 {
+						pos = 0
+					}
+					model = append(model[:pos], append([]interface{}{arg}, model[pos:]...)...)
+				}
+			case 7:
+				v, ok := a.Remove(arg)
+				if ok != (arg < len(model)) {
+					t.Fatalf("Remove(%d) found = %v with model %v", arg, ok, model)
+				}
+				if ok {
+					if v != model[arg] {
+						t.Fatalf("Remove(%d) = %v, want %v", arg, v, model[arg])
+					}
+					model = append(model[:arg], model[arg+1:]...)
+				}
+			case 8:
+				removed := a.RemoveValue(arg)
+				index := -1
+				for k, v := range model {
+					if v == arg {
+						index = k
+						break
+					}
+				}
+				if removed != (index != -1) {
+					t.Fatalf("RemoveValue(%d) = %v with model %v", arg, removed, model)
+				}
+				if removed {
+					model = append(model[:index], model[index+1:]...)
+				}
+			case 9:
+				n := len(model)
+				if arg < n {
+					n = arg
+				}
+				got := a.PopLefts(arg)
+				if n > 0 && !reflect.DeepEqual(got, model[:n]) {
+					t.Fatalf("PopLefts(%d) = %v, want %v", arg, got, model[:n])
+				}
+				model = model[n:]
+			case 10:
+				n := len(model)
+				if arg < n {
+					n = arg
+				}
+				got := a.PopRights(arg)
+				if n > 0 && !reflect.DeepEqual(got, model[len(model)-n:]) {
+					t.Fatalf("PopRights(%d) = %v, want %v", arg, got, model[len(model)-n:])
+				}
+				model = model[:len(model)-n]
+			case 11:
+				got := a.Range(arg, -1)
+				end := len(model) - 1
+				var want []interface{}
+				if arg < end {
+					want = model[arg:end]
+				}
+				if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+					t.Fatalf("Range(%d, -1) = %v, want %v", arg, got, want)
+				}
+			case 12:
+				a.PopRands(arg)
+				model = append([]interface{}{}, a.Range(0)...)
+			}
+			if a.Len() != len(model) {
+				t.Fatalf("Len = %d, want %d", a.Len(), len(model))
+			}
+			for k, v := range model {
+				if got, _ := a.Get(k); got != v {
+					t.Fatalf("Get(%d) = %v, want %v", k, got, v)
+				}
+			}
+		}
+	})
+}
+
+func TestLockDebug(t *testing.T) {
+	reports := make(chan *mutex.Report, 4)
+	mutex.EnableDebug(mutex.DebugOptions{
+		HoldThreshold: 20 * time.Millisecond,
+		Report:        func(r *mutex.Report) { reports <- r },
+	})
+	a := New(true).SetLockName("jobs")
+	b := New(true)
+	mutex.DisableDebug()
+
+	a.LockFunc(func(array []interface{}) {
+		time.Sleep(40 * time.Millisecond)
+	})
+	r := <-reports
+	if r.Kind != mutex.ReportLongHold || r.Lock != "jobs" || !strings.Contains(r.Stack, "TestLockDebug") {
+		t.Fatalf("report = %+v", r)
+	}
+
+	// Arrays are named after the constructor and the site calling it from
+	// another package, which is the testing package here.
+	b.LockFunc(func(array []interface{}) {
+		time.Sleep(40 * time.Millisecond)
+	})
+	r = <-reports
+	if !strings.HasPrefix(r.Lock, "carray.NewArraySize from ") {
+		t.Fatalf("lock named %q", r.Lock)
+	}
+}
@@ -0,0 +1,103 @@
+package cmap
+
+import "github.com/funbytes/modern-go/bytesconv"
+
+// The maps keyed by strings can be looked up by byte slices without
+// converting them to strings: the shard is found by KeyHashBytes, which
+// hashes like KeyHashStr, and the compiler does not allocate for the
+// conversion in items[string(key)]. These methods are not in the template,
+// which is also instantiated with uint32 keys.
+
+// GetShardBytes returns the shard of the string of key.
+func (m ConcurrentMap) GetShardBytes(key []byte) *shardedConcurrentMap {
+	return m[uint64(KeyHashBytes(key))%uint64(SHARD_COUNTConcurrentMap)]
+}
+
+// GetBytes is like Get with the string of key, without allocating.
+func (m ConcurrentMap) GetBytes(key []byte) (interface{}, bool) {
+	shard := m.GetShardBytes(key)
+	shard.RLock()
+	val, ok := shard.items[string(key)]
+	shard.RUnlock()
+	return val, ok
+}
+
+// HasBytes is like Has with the string of key, without allocating.
+func (m *ConcurrentMap) HasBytes(key []byte) bool {
+	shard := m.GetShardBytes(key)
+	shard.RLock()
+	_, ok := shard.items[string(key)]
+	shard.RUnlock()
+	return ok
+}
+
+// RemoveBytes is like Remove with the string of key, without allocating.
+func (m *ConcurrentMap) RemoveBytes(key []byte) {
+	shard := m.GetShardBytes(key)
+	shard.Lock()
+	// delete does not keep the key, so it may share the bytes of key.
+	delete(shard.items, bytesconv.BytesToString(key))
+	shard.Unlock()
+}
+
+// GetShardBytes returns the shard of the string of key.
+func (m ConcurrentMapStringUint64) GetShardBytes(key []byte) *shardedConcurrentMapStringUint64 {
+	return m[uint64(KeyHashBytes(key))%uint64(SHARD_COUNTConcurrentMapStringUint64)]
+}
+
+// GetBytes is like Get with the string of key, without allocating.
+func (m ConcurrentMapStringUint64) GetBytes(key []byte) (uint64, bool) {
+	shard := m.GetShardBytes(key)
+	shard.RLock()
+	val, ok := shard.items[string(key)]
+	shard.RUnlock()
+	return val, ok
+}
+
+// HasBytes is like Has with the string of key, without allocating.
+func (m *ConcurrentMapStringUint64) HasBytes(key []byte) bool {
+	shard := m.GetShardBytes(key)
+	shard.RLock()
+	_, ok := shard.items[string(key)]
+	shard.RUnlock()
+	return ok
+}
+
+// RemoveBytes is like Remove with the string of key, without allocating.
+func (m *ConcurrentMapStringUint64) RemoveBytes(key []byte) {
+	shard := m.GetShardBytes(key)
+	shard.Lock()
+	delete(shard.items, bytesconv.BytesToString(key))
+	shard.Unlock()
+}
+
+// GetShardBytes returns the shard of the string of key.
+func (m ConcurrentMapStringString) GetShardBytes(key []byte) *shardedConcurrentMapStringString {
+	return m[uint64(KeyHashBytes(key))%uint64(SHARD_COUNTConcurrentMapStringString)]
+}
+
+// GetBytes is like Get with the string of key, without allocating.
+func (m ConcurrentMapStringString) GetBytes(key []byte) (string, bool) {
+	shard := m.GetShardBytes(key)
+	shard.RLock()
+	val, ok := shard.items[string(key)]
+	shard.RUnlock()
+	return val, ok
+}
+
+// HasBytes is like Has with the string of key, without allocating.
+func (m *ConcurrentMapStringString) HasBytes(key []byte) bool {
+	shard := m.GetShardBytes(key)
+	shard.RLock()
+	_, ok := shard.items[string(key)]
+	shard.RUnlock()
+	return ok
+}
+
+// RemoveBytes is like Remove with the string of key, without allocating.
+func (m *ConcurrentMapStringString) RemoveBytes(key []byte) {
+	shard := m.GetShardBytes(key)
+	shard.Lock()
+	delete(shard.items, bytesconv.BytesToString(key))
+	shard.Unlock()
+}
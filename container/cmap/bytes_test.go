@@ -0,0 +1,98 @@
+package cmap
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/funbytes/modern-go/mutex"
+)
+
+func TestKeyHashBytes(t *testing.T) {
+	for _, key := range []string{"", "a", "key", "a much longer key with spaces"} {
+		if got, want := KeyHashBytes([]byte(key)), KeyHashStr(key); got != want {
+			t.Errorf("KeyHashBytes(%q) = %d, want %d", key, got, want)
+		}
+	}
+}
+
+func TestBytesKeys(t *testing.T) {
+	m := NewConcurrentMapStringString()
+	for i := 0; i < 100; i++ {
+		m.Set("key"+strconv.Itoa(i), strconv.Itoa(i))
+	}
+	for i := 0; i < 100; i++ {
+		key := []byte("key" + strconv.Itoa(i))
+		if v, ok := m.GetBytes(key); !ok || v != strconv.Itoa(i) {
+			t.Fatalf("GetBytes(%q) = (%q, %v)", key, v, ok)
+		}
+		if !m.HasBytes(key) {
+			t.Fatalf("HasBytes(%q) = false", key)
+		}
+	}
+	if _, ok := m.GetBytes([]byte("missing")); ok || m.HasBytes([]byte("missing")) {
+		t.Fatal("missing key found")
+	}
+
+	key := []byte("key7")
+	m.RemoveBytes(key)
+	key[0] = 'K' // the map must not keep the removed bytes
+	if m.Has("key7") || m.Count() != 99 {
+		t.Fatalf("RemoveBytes left key7, count %d", m.Count())
+	}
+
+	u := NewConcurrentMapStringUint64()
+	u.Set("a", 1)
+	if v, ok := u.GetBytes([]byte("a")); !ok || v != 1 || !u.HasBytes([]byte("a")) {
+		t.Fatalf("GetBytes(a) = (%d, %v)", v, ok)
+	}
+	u.RemoveBytes([]byte("a"))
+	if u.HasBytes([]byte("a")) {
+		t.Fatal("RemoveBytes left a")
+	}
+	i := NewConcurrentMap(false)
+	i.Set("a", 1)
+	if v, ok := i.GetBytes([]byte("a")); !ok || v != 1 {
+		t.Fatalf("GetBytes(a) = (%v, %v)", v, ok)
+	}
+	i.RemoveBytes([]byte("a"))
+	if i.HasBytes([]byte("a")) {
+		t.Fatal("RemoveBytes left a")
+	}
+}
+
+func TestBytesKeysDoNotAllocate(t *testing.T) {
+	if mutex.DebugEnabled() {
+		t.Skip("tracked locks allocate")
+	}
+	m := NewConcurrentMapStringString()
+	m.Set("a fairly long key which would be allocated", "v")
+	key := []byte("a fairly long key which would be allocated")
+	missing := []byte("another fairly long key, which is missing")
+	allocs := testing.AllocsPerRun(100, func() {
+		m.GetBytes(key)
+		m.HasBytes(key)
+		m.RemoveBytes(missing)
+	})
+	if allocs != 0 {
+		t.Fatalf("lookups allocate %v times", allocs)
+	}
+}
+
+func BenchmarkGetBytes(b *testing.B) {
+	m := NewConcurrentMapStringString()
+	// Short conversions may use a buffer on the stack, longer ones allocate.
+	m.Set("x-forwarded-for-the-original-client-address", "v")
+	key := []byte("x-forwarded-for-the-original-client-address")
+	b.Run("Get", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m.Get(string(key))
+		}
+	})
+	b.Run("GetBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m.GetBytes(key)
+		}
+	})
+}
@@ -3,7 +3,7 @@
 package cmap
 
 import (
-	"sync"
+	"github.com/funbytes/modern-go/internal/rwmutex"
 )
 
 // A thread safe map.
@@ -18,7 +18,7 @@ type ConcurrentMap []*shardedConcurrentMap
 
 type shardedConcurrentMap struct {
 	items map[string]interface{}
-	sync.RWMutex
+	*rwmutex.RWMutex
 }
 
 // Used by the Iter & IterBuffered functions to wrap two variables together over a channel,
@@ -27,10 +27,11 @@ type TupleConcurrentMap struct {
 	Val interface{}
 }
 
-func NewConcurrentMap() ConcurrentMap {
+// The map is concurrent-safe unless safe is given as false.
+func NewConcurrentMap(safe ...bool) ConcurrentMap {
 	this := make(ConcurrentMap, SHARD_COUNTConcurrentMap)
 	for i := 0; i < SHARD_COUNTConcurrentMap; i++ {
-		this[i] = &shardedConcurrentMap{items: make(map[string]interface{})}
+		this[i] = &shardedConcurrentMap{items: make(map[string]interface{}), RWMutex: rwmutex.NewDefaultSafe(safe...)}
 	}
 	return this
 }
@@ -139,6 +140,11 @@ func (m ConcurrentMap) Count() int {
 	return count
 }
 
+// Len returns the number of elements, like Count.
+func (m ConcurrentMap) Len() int {
+	return m.Count()
+}
+
 func (m *ConcurrentMap) Has(key string) bool {
 	shard := m.GetShard(key)
 	shard.RLock()
@@ -164,8 +170,20 @@ func (m ConcurrentMap) GetAndRemove(key string) (interface{}, bool) {
 }
 
 // Returns an iterator which could be used in a for range loop.
+// A map which is not concurrent-safe is read before Iter returns, so that
+// the caller may write to it while draining the channel.
 func (m ConcurrentMap) Iter() <-chan TupleConcurrentMap {
 	ch := make(chan TupleConcurrentMap)
+	if !m.isSafe() {
+		tuples := m.tuples()
+		go func() {
+			for _, t := range tuples {
+				ch <- t
+			}
+			close(ch)
+		}()
+		return ch
+	}
 	go func() {
 		for _, shard := range m {
 			shard.RLock()
@@ -180,8 +198,19 @@ func (m ConcurrentMap) Iter() <-chan TupleConcurrentMap {
 }
 
 // Returns a buffered iterator which could be used in a for range loop.
+// A map which is not concurrent-safe is read before IterBuffered returns,
+// like Iter.
 func (m ConcurrentMap) IterBuffered() <-chan TupleConcurrentMap {
 	ch := make(chan TupleConcurrentMap, m.Count())
+	if !m.isSafe() {
+		for _, shard := range m {
+			for key, val := range shard.items {
+				ch <- TupleConcurrentMap{key, val}
+			}
+		}
+		close(ch)
+		return ch
+	}
 	go func() {
 		// Foreach shard.
 		for _, shard := range m {
@@ -196,3 +225,19 @@ func (m ConcurrentMap) IterBuffered() <-chan TupleConcurrentMap {
 	}()
 	return ch
 }
+
+// isSafe reports whether the shards of the map are concurrent-safe.
+func (m ConcurrentMap) isSafe() bool {
+	return len(m) > 0 && m[0].IsSafe()
+}
+
+// tuples returns all key, value pairs of a map which is not concurrent-safe.
+func (m ConcurrentMap) tuples() []TupleConcurrentMap {
+	tuples := make([]TupleConcurrentMap, 0, m.Count())
+	for _, shard := range m {
+		for key, val := range shard.items {
+			tuples = append(tuples, TupleConcurrentMap{key, val})
+		}
+	}
+	return tuples
+}
@@ -3,7 +3,7 @@
 package cmap
 
 import (
-	"sync"
+	"github.com/funbytes/modern-go/internal/rwmutex"
 )
 
 // A thread safe map.
@@ -18,7 +18,7 @@ type ConcurrentMapStringString []*shardedConcurrentMapStringString
 
 type shardedConcurrentMapStringString struct {
 	items map[string]string
-	sync.RWMutex
+	*rwmutex.RWMutex
 }
 
 // Used by the Iter & IterBuffered functions to wrap two variables together over a channel,
@@ -27,10 +27,11 @@ type TupleConcurrentMapStringString struct {
 	Val string
 }
 
-func NewConcurrentMapStringString() ConcurrentMapStringString {
+// The map is concurrent-safe unless safe is given as false.
+func NewConcurrentMapStringString(safe ...bool) ConcurrentMapStringString {
 	this := make(ConcurrentMapStringString, SHARD_COUNTConcurrentMapStringString)
 	for i := 0; i < SHARD_COUNTConcurrentMapStringString; i++ {
-		this[i] = &shardedConcurrentMapStringString{items: make(map[string]string)}
+		this[i] = &shardedConcurrentMapStringString{items: make(map[string]string), RWMutex: rwmutex.NewDefaultSafe(safe...)}
 	}
 	return this
 }
@@ -139,6 +140,11 @@ func (m ConcurrentMapStringString) Count() int {
 	return count
 }
 
+// Len returns the number of elements, like Count.
+func (m ConcurrentMapStringString) Len() int {
+	return m.Count()
+}
+
 func (m *ConcurrentMapStringString) Has(key string) bool {
 	shard := m.GetShard(key)
 	shard.RLock()
@@ -164,8 +170,20 @@ func (m ConcurrentMapStringString) GetAndRemove(key string) (string, bool) {
 }
 
 // Returns an iterator which could be used in a for range loop.
+// A map which is not concurrent-safe is read before Iter returns, so that
+// the caller may write to it while draining the channel.
 func (m ConcurrentMapStringString) Iter() <-chan TupleConcurrentMapStringString {
 	ch := make(chan TupleConcurrentMapStringString)
+	if !m.isSafe() {
+		tuples := m.tuples()
+		go func() {
+			for _, t := range tuples {
+				ch <- t
+			}
+			close(ch)
+		}()
+		return ch
+	}
 	go func() {
 		for _, shard := range m {
 			shard.RLock()
@@ -180,8 +198,19 @@ func (m ConcurrentMapStringString) Iter() <-chan TupleConcurrentMapStringString
 }
 
 // Returns a buffered iterator which could be used in a for range loop.
+// A map which is not concurrent-safe is read before IterBuffered returns,
+// like Iter.
 func (m ConcurrentMapStringString) IterBuffered() <-chan TupleConcurrentMapStringString {
 	ch := make(chan TupleConcurrentMapStringString, m.Count())
+	if !m.isSafe() {
+		for _, shard := range m {
+			for key, val := range shard.items {
+				ch <- TupleConcurrentMapStringString{key, val}
+			}
+		}
+		close(ch)
+		return ch
+	}
 	go func() {
 		// Foreach shard.
 		for _, shard := range m {
@@ -196,3 +225,19 @@ func (m ConcurrentMapStringString) IterBuffered() <-chan TupleConcurrentMapStrin
 	}()
 	return ch
 }
+
+// isSafe reports whether the shards of the map are concurrent-safe.
+func (m ConcurrentMapStringString) isSafe() bool {
+	return len(m) > 0 && m[0].IsSafe()
+}
+
+// tuples returns all key, value pairs of a map which is not concurrent-safe.
+func (m ConcurrentMapStringString) tuples() []TupleConcurrentMapStringString {
+	tuples := make([]TupleConcurrentMapStringString, 0, m.Count())
+	for _, shard := range m {
+		for key, val := range shard.items {
+			tuples = append(tuples, TupleConcurrentMapStringString{key, val})
+		}
+	}
+	return tuples
+}
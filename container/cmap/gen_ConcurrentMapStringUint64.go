@@ -3,7 +3,7 @@
 package cmap
 
 import (
-	"sync"
+	"github.com/funbytes/modern-go/internal/rwmutex"
 )
 
 // A thread safe map.
@@ -18,7 +18,7 @@ type ConcurrentMapStringUint64 []*shardedConcurrentMapStringUint64
 
 type shardedConcurrentMapStringUint64 struct {
 	items map[string]uint64
-	sync.RWMutex
+	*rwmutex.RWMutex
 }
 
 // Used by the Iter & IterBuffered functions to wrap two variables together over a channel,
@@ -27,10 +27,11 @@ type TupleConcurrentMapStringUint64 struct {
 	Val uint64
 }
 
-func NewConcurrentMapStringUint64() ConcurrentMapStringUint64 {
+// The map is concurrent-safe unless safe is given as false.
+func NewConcurrentMapStringUint64(safe ...bool) ConcurrentMapStringUint64 {
 	this := make(ConcurrentMapStringUint64, SHARD_COUNTConcurrentMapStringUint64)
 	for i := 0; i < SHARD_COUNTConcurrentMapStringUint64; i++ {
-		this[i] = &shardedConcurrentMapStringUint64{items: make(map[string]uint64)}
+		this[i] = &shardedConcurrentMapStringUint64{items: make(map[string]uint64), RWMutex: rwmutex.NewDefaultSafe(safe...)}
 	}
 	return this
 }
@@ -139,6 +140,11 @@ func (m ConcurrentMapStringUint64) Count() int {
 	return count
 }
 
+// Len returns the number of elements, like Count.
+func (m ConcurrentMapStringUint64) Len() int {
+	return m.Count()
+}
+
 func (m *ConcurrentMapStringUint64) Has(key string) bool {
 	shard := m.GetShard(key)
 	shard.RLock()
@@ -164,8 +170,20 @@ func (m ConcurrentMapStringUint64) GetAndRemove(key string) (uint64, bool) {
 }
 
 // Returns an iterator which could be used in a for range loop.
+// A map which is not concurrent-safe is read before Iter returns, so that
+// the caller may write to it while draining the channel.
 func (m ConcurrentMapStringUint64) Iter() <-chan TupleConcurrentMapStringUint64 {
 	ch := make(chan TupleConcurrentMapStringUint64)
+	if !m.isSafe() {
+		tuples := m.tuples()
+		go func() {
+			for _, t := range tuples {
+				ch <- t
+			}
+			close(ch)
+		}()
+		return ch
+	}
 	go func() {
 		for _, shard := range m {
 			shard.RLock()
@@ -180,8 +198,19 @@ func (m ConcurrentMapStringUint64) Iter() <-chan TupleConcurrentMapStringUint64
 }
 
 // Returns a buffered iterator which could be used in a for range loop.
+// A map which is not concurrent-safe is read before IterBuffered returns,
+// like Iter.
 func (m ConcurrentMapStringUint64) IterBuffered() <-chan TupleConcurrentMapStringUint64 {
 	ch := make(chan TupleConcurrentMapStringUint64, m.Count())
+	if !m.isSafe() {
+		for _, shard := range m {
+			for key, val := range shard.items {
+				ch <- TupleConcurrentMapStringUint64{key, val}
+			}
+		}
+		close(ch)
+		return ch
+	}
 	go func() {
 		// Foreach shard.
 		for _, shard := range m {
@@ -196,3 +225,19 @@ func (m ConcurrentMapStringUint64) IterBuffered() <-chan TupleConcurrentMapStrin
 	}()
 	return ch
 }
+
+// isSafe reports whether the shards of the map are concurrent-safe.
+func (m ConcurrentMapStringUint64) isSafe() bool {
+	return len(m) > 0 && m[0].IsSafe()
+}
+
+// tuples returns all key, value pairs of a map which is not concurrent-safe.
+func (m ConcurrentMapStringUint64) tuples() []TupleConcurrentMapStringUint64 {
+	tuples := make([]TupleConcurrentMapStringUint64, 0, m.Count())
+	for _, shard := range m {
+		for key, val := range shard.items {
+			tuples = append(tuples, TupleConcurrentMapStringUint64{key, val})
+		}
+	}
+	return tuples
+}
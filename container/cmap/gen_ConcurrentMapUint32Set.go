@@ -3,7 +3,7 @@
 package cmap
 
 import (
-	"sync"
+	"github.com/funbytes/modern-go/internal/rwmutex"
 )
 
 // A thread safe map.
@@ -18,7 +18,7 @@ type ConcurrentMapUint32Set []*shardedConcurrentMapUint32Set
 
 type shardedConcurrentMapUint32Set struct {
 	items map[uint32]struct{}
-	sync.RWMutex
+	*rwmutex.RWMutex
 }
 
 // Used by the Iter & IterBuffered functions to wrap two variables together over a channel,
@@ -27,10 +27,11 @@ type TupleConcurrentMapUint32Set struct {
 	Val struct{}
 }
 
-func NewConcurrentMapUint32Set() ConcurrentMapUint32Set {
+// The map is concurrent-safe unless safe is given as false.
+func NewConcurrentMapUint32Set(safe ...bool) ConcurrentMapUint32Set {
 	this := make(ConcurrentMapUint32Set, SHARD_COUNTConcurrentMapUint32Set)
 	for i := 0; i < SHARD_COUNTConcurrentMapUint32Set; i++ {
-		this[i] = &shardedConcurrentMapUint32Set{items: make(map[uint32]struct{})}
+		this[i] = &shardedConcurrentMapUint32Set{items: make(map[uint32]struct{}), RWMutex: rwmutex.NewDefaultSafe(safe...)}
 	}
 	return this
 }
@@ -139,6 +140,11 @@ func (m ConcurrentMapUint32Set) Count() int {
 	return count
 }
 
+// Len returns the number of elements, like Count.
+func (m ConcurrentMapUint32Set) Len() int {
+	return m.Count()
+}
+
 func (m *ConcurrentMapUint32Set) Has(key uint32) bool {
 	shard := m.GetShard(key)
 	shard.RLock()
@@ -164,8 +170,20 @@ func (m ConcurrentMapUint32Set) GetAndRemove(key uint32) (struct{}, bool) {
 }
 
 // Returns an iterator which could be used in a for range loop.
+// A map which is not concurrent-safe is read before Iter returns, so that
+// the caller may write to it while draining the channel.
 func (m ConcurrentMapUint32Set) Iter() <-chan TupleConcurrentMapUint32Set {
 	ch := make(chan TupleConcurrentMapUint32Set)
+	if !m.isSafe() {
+		tuples := m.tuples()
+		go func() {
+			for _, t := range tuples {
+				ch <- t
+			}
+			close(ch)
+		}()
+		return ch
+	}
 	go func() {
 		for _, shard := range m {
 			shard.RLock()
@@ -180,8 +198,19 @@ func (m ConcurrentMapUint32Set) Iter() <-chan TupleConcurrentMapUint32Set {
 }
 
 // Returns a buffered iterator which could be used in a for range loop.
+// A map which is not concurrent-safe is read before IterBuffered returns,
+// like Iter.
 func (m ConcurrentMapUint32Set) IterBuffered() <-chan TupleConcurrentMapUint32Set {
 	ch := make(chan TupleConcurrentMapUint32Set, m.Count())
+	if !m.isSafe() {
+		for _, shard := range m {
+			for key, val := range shard.items {
+				ch <- TupleConcurrentMapUint32Set{key, val}
+			}
+		}
+		close(ch)
+		return ch
+	}
 	go func() {
 		// Foreach shard.
 		for _, shard := range m {
@@ -196,3 +225,19 @@ func (m ConcurrentMapUint32Set) IterBuffered() <-chan TupleConcurrentMapUint32Se
 	}()
 	return ch
 }
+
+// isSafe reports whether the shards of the map are concurrent-safe.
+func (m ConcurrentMapUint32Set) isSafe() bool {
+	return len(m) > 0 && m[0].IsSafe()
+}
+
+// tuples returns all key, value pairs of a map which is not concurrent-safe.
+func (m ConcurrentMapUint32Set) tuples() []TupleConcurrentMapUint32Set {
+	tuples := make([]TupleConcurrentMapUint32Set, 0, m.Count())
+	for _, shard := range m {
+		for key, val := range shard.items {
+			tuples = append(tuples, TupleConcurrentMapUint32Set{key, val})
+		}
+	}
+	return tuples
+}
@@ -3,7 +3,7 @@
 package cmap
 
 import (
-	"sync"
+	"github.com/funbytes/modern-go/internal/rwmutex"
 )
 
 // A thread safe map.
@@ -18,7 +18,7 @@ type ConcurrentMapUint32Uint64 []*shardedConcurrentMapUint32Uint64
 
 type shardedConcurrentMapUint32Uint64 struct {
 	items map[uint32]uint64
-	sync.RWMutex
+	*rwmutex.RWMutex
 }
 
 // Used by the Iter & IterBuffered functions to wrap two variables together over a channel,
@@ -27,10 +27,11 @@ type TupleConcurrentMapUint32Uint64 struct {
 	Val uint64
 }
 
-func NewConcurrentMapUint32Uint64() ConcurrentMapUint32Uint64 {
+// The map is concurrent-safe unless safe is given as false.
+func NewConcurrentMapUint32Uint64(safe ...bool) ConcurrentMapUint32Uint64 {
 	this := make(ConcurrentMapUint32Uint64, SHARD_COUNTConcurrentMapUint32Uint64)
 	for i := 0; i < SHARD_COUNTConcurrentMapUint32Uint64; i++ {
-		this[i] = &shardedConcurrentMapUint32Uint64{items: make(map[uint32]uint64)}
+		this[i] = &shardedConcurrentMapUint32Uint64{items: make(map[uint32]uint64), RWMutex: rwmutex.NewDefaultSafe(safe...)}
 	}
 	return this
 }
@@ -139,6 +140,11 @@ func (m ConcurrentMapUint32Uint64) Count() int {
 	return count
 }
 
+// Len returns the number of elements, like Count.
+func (m ConcurrentMapUint32Uint64) Len() int {
+	return m.Count()
+}
+
 func (m *ConcurrentMapUint32Uint64) Has(key uint32) bool {
 	shard := m.GetShard(key)
 	shard.RLock()
@@ -164,8 +170,20 @@ func (m ConcurrentMapUint32Uint64) GetAndRemove(key uint32) (uint64, bool) {
 }
 
 // Returns an iterator which could be used in a for range loop.
+// A map which is not concurrent-safe is read before Iter returns, so that
+// the caller may write to it while draining the channel.
 func (m ConcurrentMapUint32Uint64) Iter() <-chan TupleConcurrentMapUint32Uint64 {
 	ch := make(chan TupleConcurrentMapUint32Uint64)
+	if !m.isSafe() {
+		tuples := m.tuples()
+		go func() {
+			for _, t := range tuples {
+				ch <- t
+			}
+			close(ch)
+		}()
+		return ch
+	}
 	go func() {
 		for _, shard := range m {
 			shard.RLock()
@@ -180,8 +198,19 @@ func (m ConcurrentMapUint32Uint64) Iter() <-chan TupleConcurrentMapUint32Uint64
 }
 
 // Returns a buffered iterator which could be used in a for range loop.
+// A map which is not concurrent-safe is read before IterBuffered returns,
+// like Iter.
 func (m ConcurrentMapUint32Uint64) IterBuffered() <-chan TupleConcurrentMapUint32Uint64 {
 	ch := make(chan TupleConcurrentMapUint32Uint64, m.Count())
+	if !m.isSafe() {
+		for _, shard := range m {
+			for key, val := range shard.items {
+				ch <- TupleConcurrentMapUint32Uint64{key, val}
+			}
+		}
+		close(ch)
+		return ch
+	}
 	go func() {
 		// Foreach shard.
 		for _, shard := range m {
@@ -196,3 +225,19 @@ func (m ConcurrentMapUint32Uint64) IterBuffered() <-chan TupleConcurrentMapUint3
 	}()
 	return ch
 }
+
+// isSafe reports whether the shards of the map are concurrent-safe.
+func (m ConcurrentMapUint32Uint64) isSafe() bool {
+	return len(m) > 0 && m[0].IsSafe()
+}
+
+// tuples returns all key, value pairs of a map which is not concurrent-safe.
+func (m ConcurrentMapUint32Uint64) tuples() []TupleConcurrentMapUint32Uint64 {
+	tuples := make([]TupleConcurrentMapUint32Uint64, 0, m.Count())
+	for _, shard := range m {
+		for key, val := range shard.items {
+			tuples = append(tuples, TupleConcurrentMapUint32Uint64{key, val})
+		}
+	}
+	return tuples
+}
@@ -1,5 +1,7 @@
 package cmap
 
+import "github.com/funbytes/modern-go/bytesconv"
+
 func KeyHashStr(key string) uint32 {
 	hash := uint32(2166136261)
 	const prime32 = uint32(16777619)
@@ -10,6 +12,12 @@ func KeyHashStr(key string) uint32 {
 	return hash
 }
 
+// KeyHashBytes hashes the string of key like KeyHashStr, without converting
+// key to a string.
+func KeyHashBytes(key []byte) uint32 {
+	return KeyHashStr(bytesconv.BytesToString(key))
+}
+
 func KeyHashUint32(key uint32) uint32 {
 	return key
 }
@@ -0,0 +1,14 @@
+// Package container defines the interface shared by the containers of its
+// subpackages.
+package container
+
+// Container is implemented by the stacks, queues, sorted sets and
+// concurrent maps of the subpackages.
+type Container interface {
+	// Len returns the number of elements.
+	Len() int
+	// IsEmpty reports whether there are no elements.
+	IsEmpty() bool
+	// Clear removes all elements.
+	Clear()
+}
@@ -0,0 +1,260 @@
+// Package leaderboard provides leaderboards with tie-aware ranking on top
+// of skiplist.Set.
+package leaderboard
+
+import (
+	"math"
+	"sync"
+
+	"github.com/funbytes/modern-go/container/skiplist"
+)
+
+// ErrNotFound is matched by the errors returned for a key which is not on
+// the board. It is skiplist.ErrNotFound, so errors.Is matches the errors of
+// both packages.
+var ErrNotFound = skiplist.ErrNotFound
+
+// RankMode is the way entries with equal scores are ranked.
+type RankMode int
+
+const (
+	// Competition gives equal scores the same rank and leaves a gap
+	// after them, like 1, 2, 2, 4.
+	Competition RankMode = iota
+	// Dense gives equal scores the same rank without gaps, like 1, 2, 2, 3.
+	Dense
+	// Ordinal gives every entry a distinct rank, like 1, 2, 3, 4, where
+	// equal scores are ordered by key.
+	Ordinal
+)
+
+// Order tells which scores rank first.
+type Order int
+
+const (
+	// HighFirst ranks the highest score first, like points in a game.
+	HighFirst Order = iota
+	// LowFirst ranks the lowest score first, like lap times in a race.
+	LowFirst
+)
+
+// Entry is a key on a board with its score and rank, ranks start from 1.
+type Entry struct {
+	Key   string  `json:"key"`
+	Score float64 `json:"score"`
+	Rank  int64   `json:"rank"`
+}
+
+// Board is a leaderboard, it is safe for concurrent use. Its lock guards set
+// and scores together, so set is not locked on its own.
+type Board struct {
+	mu    sync.RWMutex
+	mode  RankMode
+	order Order
+	// set holds the entries ordered best first. With HighFirst the
+	// scores are stored negated, so that ties are ordered by key in
+	// both orders.
+	set *skiplist.Set
+	// scores counts the entries of every distinct stored score,
+	// which gives dense ranks.
+	scores *skiplist.SkipList[float64, int64]
+}
+
+// New creates and returns an empty Board.
+func New(mode RankMode, order Order) *Board {
+	return &Board{
+		mode:   mode,
+		order:  order,
+		set:    skiplist.NewSet(skiplist.WithSafe(false)),
+		scores: skiplist.NewOrdered[float64, int64](),
+	}
+}
+
+// stored converts between the scores of entries and the scores in set.
+func (b *Board) stored(score float64) float64 {
+	if b.order == HighFirst {
+		return -score
+	}
+	return score
+}
+
+// Set sets the score of key, adding key if it is not on the board.
+// A NaN score leaves the board unchanged.
+func (b *Board) Set(key string, score float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.setWithoutLock(key, score)
+}
+
+func (b *Board) setWithoutLock(key string, score float64) {
+	if math.IsNaN(score) {
+		return
+	}
+	if cur, err := b.set.GetScore(key); err == nil {
+		b.countScore(cur, -1)
+	}
+	b.set.Set(key, b.stored(score))
+	b.countScore(b.stored(score), 1)
+}
+
+// Incr adds delta to the score of key and returns the new score.
+// A key not on the board is added with delta as its score. The board is left
+// unchanged if the new score is NaN.
+func (b *Board) Incr(key string, delta float64) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	score := delta
+	if cur, err := b.set.GetScore(key); err == nil {
+		score += b.stored(cur)
+	}
+	b.setWithoutLock(key, score)
+	return score
+}
+
+// Remove removes key from the board, and reports whether it was on it.
+func (b *Board) Remove(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cur, err := b.set.GetScore(key)
+	if err != nil {
+		return false
+	}
+	b.set.Del(key)
+	b.countScore(cur, -1)
+	return true
+}
+
+// countScore adds n to the number of entries with the stored score.
+func (b *Board) countScore(score float64, n int64) {
+	count, _ := b.scores.Get(score)
+	if count+n == 0 {
+		b.scores.Delete(score)
+	} else {
+		b.scores.Put(score, count+n)
+	}
+}
+
+// Len returns the number of entries.
+func (b *Board) Len() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.set.GetLength()
+}
+
+// Get returns the entry of key, or an error matching ErrNotFound.
+func (b *Board) Get(key string) (Entry, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.get(key)
+}
+
+func (b *Board) get(key string) (Entry, error) {
+	score, err := b.set.GetScore(key)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Key: key, Score: b.stored(score), Rank: b.rank(key, score)}, nil
+}
+
+// rank returns the rank of key with the stored score.
+func (b *Board) rank(key string, score float64) int64 {
+	switch b.mode {
+	case Dense:
+		return b.scores.Rank(score)
+	case Ordinal:
+		rank, _ := b.set.GetRank(key)
+		return rank
+	}
+	return b.set.CountInScore(skiplist.ScoreRange{Min: math.Inf(-1), Max: score, MaxExclusive: true}) + 1
+}
+
+// Top returns the best n entries.
+func (b *Board) Top(n int64) []Entry {
+	return b.Range(1, n)
+}
+
+// Range returns the entries from the start-th best to the stop-th best,
+// both inclusive and starting from 1.
+func (b *Board) Range(start, stop int64) []Entry {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if start < 1 {
+		start = 1
+	}
+	if start > stop {
+		return nil
+	}
+	return b.entries(start, b.set.RangeByRank(start, stop, false))
+}
+
+// entries ranks nodes, which are consecutive entries from the position start.
+func (b *Board) entries(start int64, nodes []skiplist.Node) []Entry {
+	entries := make([]Entry, len(nodes))
+	for i, n := range nodes {
+		e := &entries[i]
+		e.Key, e.Score = n.Key, b.stored(n.Score)
+		switch {
+		case i == 0:
+			e.Rank = b.rank(n.Key, n.Score)
+		case b.mode == Ordinal:
+			e.Rank = start + int64(i)
+		case n.Score == nodes[i-1].Score:
+			e.Rank = entries[i-1].Rank
+		case b.mode == Dense:
+			e.Rank = entries[i-1].Rank + 1
+		default:
+			e.Rank = start + int64(i)
+		}
+	}
+	return entries
+}
+
+// AroundMe returns up to before entries ranked better than key, the entry
+// of key, and up to after entries ranked worse than key, best first. It
+// returns an error matching ErrNotFound if key is not on the board.
+func (b *Board) AroundMe(key string, before, after int64) ([]Entry, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	pos, err := b.set.GetRank(key)
+	if err != nil {
+		return nil, err
+	}
+	start := pos - before
+	if start < 1 {
+		start = 1
+	}
+	return b.entries(start, b.set.RangeByRank(start, pos+after, false)), nil
+}
+
+// Percentile returns the percentage of entries with a worse score than key,
+// or an error matching ErrNotFound.
+func (b *Board) Percentile(key string) (float64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	score, err := b.set.GetScore(key)
+	if err != nil {
+		return 0, err
+	}
+	worse := b.set.CountInScore(skiplist.ScoreRange{Min: score, Max: math.Inf(1), MinExclusive: true})
+	return 100 * float64(worse) / float64(b.set.GetLength()), nil
+}
+
+// AtPercentile returns the entry at percentile p by the nearest-rank method,
+// which is the best entry with at least p percent of all entries ranked no
+// better than it. p is clamped to [0, 100]. It returns false if the board is empty.
+func (b *Board) AtPercentile(p float64) (Entry, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	n := b.set.GetLength()
+	if n == 0 {
+		return Entry{}, false
+	}
+	p = math.Max(0, math.Min(100, p))
+	// k entries, counted from the worst, are no better than the result.
+	k := int64(math.Ceil(p / 100 * float64(n)))
+	if k < 1 {
+		k = 1
+	}
+	nodes := b.set.RangeByRank(n-k+1, n-k+1, false)
+	return b.entries(n-k+1, nodes)[0], true
+}
@@ -0,0 +1,127 @@
+package leaderboard
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/funbytes/modern-go/container/skiplist"
+)
+
+func newBoard(mode RankMode, order Order) *Board {
+	b := New(mode, order)
+	for key, score := range map[string]float64{"a": 50, "b": 80, "c": 80, "d": 70, "e": 80, "f": 10} {
+		b.Set(key, score)
+	}
+	return b
+}
+
+func TestRankModes(t *testing.T) {
+	cases := []struct {
+		mode  RankMode
+		order Order
+		want  []Entry
+	}{
+		{Competition, HighFirst, []Entry{{"b", 80, 1}, {"c", 80, 1}, {"e", 80, 1}, {"d", 70, 4}, {"a", 50, 5}, {"f", 10, 6}}},
+		{Dense, HighFirst, []Entry{{"b", 80, 1}, {"c", 80, 1}, {"e", 80, 1}, {"d", 70, 2}, {"a", 50, 3}, {"f", 10, 4}}},
+		{Ordinal, HighFirst, []Entry{{"b", 80, 1}, {"c", 80, 2}, {"e", 80, 3}, {"d", 70, 4}, {"a", 50, 5}, {"f", 10, 6}}},
+		{Competition, LowFirst, []Entry{{"f", 10, 1}, {"a", 50, 2}, {"d", 70, 3}, {"b", 80, 4}, {"c", 80, 4}, {"e", 80, 4}}},
+		{Dense, LowFirst, []Entry{{"f", 10, 1}, {"a", 50, 2}, {"d", 70, 3}, {"b", 80, 4}, {"c", 80, 4}, {"e", 80, 4}}},
+	}
+	for _, c := range cases {
+		b := newBoard(c.mode, c.order)
+		if got := b.Top(10); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Top(mode %d, order %d) = %v, want %v", c.mode, c.order, got, c.want)
+		}
+		for i, want := range c.want {
+			if got, err := b.Get(want.Key); err != nil || got != want {
+				t.Errorf("Get(%s) = %v, %v, want %v", want.Key, got, err, want)
+			}
+			if got := b.Range(int64(i+1), int64(i+1)); len(got) != 1 || got[0] != want {
+				t.Errorf("Range(%d, %d) = %v, want %v", i+1, i+1, got, want)
+			}
+		}
+	}
+}
+
+func TestUpdates(t *testing.T) {
+	b := newBoard(Dense, HighFirst)
+	if score := b.Incr("f", 75); score != 85 {
+		t.Errorf("Incr = %v, want 85", score)
+	}
+	b.Set("c", 70)
+	b.Set("c", math.NaN())
+	b.Set("nan", math.NaN())
+	if !b.Remove("a") || b.Remove("a") {
+		t.Error("Remove should report whether the key was on the board")
+	}
+	want := []Entry{{"f", 85, 1}, {"b", 80, 2}, {"e", 80, 2}, {"c", 70, 3}, {"d", 70, 3}}
+	if got := b.Top(10); !reflect.DeepEqual(got, want) {
+		t.Errorf("Top = %v, want %v", got, want)
+	}
+	if _, err := b.Get("a"); !errors.Is(err, ErrNotFound) || !errors.Is(err, skiplist.ErrNotFound) {
+		t.Errorf("Get of a removed key = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAroundMe(t *testing.T) {
+	b := newBoard(Competition, HighFirst)
+	got, err := b.AroundMe("d", 2, 1)
+	want := []Entry{{"c", 80, 1}, {"e", 80, 1}, {"d", 70, 4}, {"a", 50, 5}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("AroundMe(d, 2, 1) = %v, %v, want %v", got, err, want)
+	}
+	got, _ = b.AroundMe("b", 5, 0)
+	if !reflect.DeepEqual(got, []Entry{{"b", 80, 1}}) {
+		t.Errorf("AroundMe(b, 5, 0) = %v", got)
+	}
+	if _, err := b.AroundMe("x", 1, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AroundMe of a missing key = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	b := newBoard(Competition, HighFirst)
+	for key, want := range map[string]float64{"b": 50, "d": 100 * 2.0 / 6, "f": 0} {
+		if got, err := b.Percentile(key); err != nil || got != want {
+			t.Errorf("Percentile(%s) = %v, %v, want %v", key, got, err, want)
+		}
+	}
+	for p, want := range map[float64]string{0: "f", 17: "a", 50: "d", 51: "e", 100: "b"} {
+		if got, ok := b.AtPercentile(p); !ok || got.Key != want {
+			t.Errorf("AtPercentile(%v) = %v, want %s", p, got, want)
+		}
+	}
+	if _, ok := New(Dense, HighFirst).AtPercentile(50); ok {
+		t.Error("AtPercentile on an empty board should fail")
+	}
+}
+
+func TestWindowed(t *testing.T) {
+	now := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC) // a Wednesday
+	w := NewWindowed(Competition, HighFirst, Daily, nil)
+	w.now = func() time.Time { return now }
+	w.start = Daily.windowStart(now)
+	w.Current().Set("a", 1)
+	if w.Previous() != nil {
+		t.Error("Previous before the first rollover should be nil")
+	}
+	now = now.Add(2 * time.Hour)
+	if w.Current().Len() != 0 || w.Previous().Len() != 1 {
+		t.Error("the board did not roll over at midnight")
+	}
+	if !w.Start().Equal(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Start = %v", w.Start())
+	}
+	w.Current().Set("b", 1)
+	now = now.Add(72 * time.Hour)
+	if w.Current().Len() != 0 || w.Previous().Len() != 0 {
+		t.Error("after idle windows the previous board should be empty")
+	}
+
+	if got := Weekly.windowStart(time.Date(2024, 5, 5, 12, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2024, 4, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("weekly window of a Sunday starts %v, want Monday 2024-04-29", got)
+	}
+}
@@ -0,0 +1,103 @@
+package leaderboard
+
+import (
+	"sync"
+	"time"
+)
+
+// Period is the length of the windows of a Windowed board.
+type Period int
+
+const (
+	// Daily windows start at midnight.
+	Daily Period = iota
+	// Weekly windows start at midnight on Monday.
+	Weekly
+)
+
+// windowStart returns the start of the window of period containing t.
+func (p Period) windowStart(t time.Time) time.Time {
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if p == Weekly {
+		// time.Sunday is 0, Monday starts the week.
+		start = start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+	}
+	return start
+}
+
+func (p Period) next(start time.Time) time.Time {
+	if p == Weekly {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// Windowed is a leaderboard which starts over at the beginning of every
+// daily or weekly window. The rollover happens automatically on the first
+// access in a new window, and the board of the window before stays
+// available as Previous.
+type Windowed struct {
+	mu       sync.Mutex
+	mode     RankMode
+	order    Order
+	period   Period
+	loc      *time.Location
+	now      func() time.Time
+	start    time.Time
+	current  *Board
+	previous *Board
+}
+
+// NewWindowed creates and returns a Windowed board, whose windows start in
+// the time zone loc, or in UTC if loc is nil.
+func NewWindowed(mode RankMode, order Order, period Period, loc *time.Location) *Windowed {
+	if loc == nil {
+		loc = time.UTC
+	}
+	w := &Windowed{mode: mode, order: order, period: period, loc: loc, now: time.Now}
+	w.start = period.windowStart(w.now().In(loc))
+	w.current = New(mode, order)
+	return w
+}
+
+// Current returns the board of the current window.
+func (w *Windowed) Current() *Board {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rollover()
+	return w.current
+}
+
+// Previous returns the board of the window just before the current one,
+// or nil if there is none. It is empty if nothing was recorded then.
+func (w *Windowed) Previous() *Board {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rollover()
+	return w.previous
+}
+
+// Start returns the start of the current window.
+func (w *Windowed) Start() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rollover()
+	return w.start
+}
+
+// rollover starts a new window if the current one is over.
+func (w *Windowed) rollover() {
+	now := w.now().In(w.loc)
+	if now.Before(w.period.next(w.start)) {
+		return
+	}
+	start := w.period.windowStart(now)
+	if start.Equal(w.period.next(w.start)) {
+		w.previous = w.current
+	} else {
+		// Whole windows passed without any access.
+		w.previous = New(w.mode, w.order)
+	}
+	w.start = start
+	w.current = New(w.mode, w.order)
+}
@@ -0,0 +1,230 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned when pushing to a closed queue, or popping from a
+// closed queue which is empty.
+var ErrClosed = errors.New("queue: closed")
+
+// BlockingQueue is a typed FIFO queue backed by a ring buffer, whose pops
+// block until an element is pushed and whose pushes block while a bounded
+// queue is full. It is safe for concurrent use.
+type BlockingQueue[T any] struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	buf      []T
+	head     int // index of the front element in buf
+	length   int
+	capacity int // 0 for an unbounded queue
+	closed   bool
+}
+
+// NewBlockingQueue creates and returns an empty BlockingQueue holding at most
+// capacity elements, or any number if capacity is not positive.
+func NewBlockingQueue[T any](capacity int) *BlockingQueue[T] {
+	if capacity < 0 {
+		capacity = 0
+	}
+	q := &BlockingQueue[T]{capacity: capacity}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	return q
+}
+
+// Length returns the number of elements in the queue.
+func (q *BlockingQueue[T]) Length() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.length
+}
+
+// Len returns the number of elements in the queue.
+func (q *BlockingQueue[T]) Len() int {
+	return q.Length()
+}
+
+// IsEmpty reports whether the queue has no elements.
+func (q *BlockingQueue[T]) IsEmpty() bool {
+	return q.Length() == 0
+}
+
+// Cap returns the capacity of the queue, or 0 if it is unbounded.
+func (q *BlockingQueue[T]) Cap() int {
+	return q.capacity
+}
+
+// Push appends v to the queue, waiting while the queue is full. It returns
+// ErrClosed if the queue is closed, or the error of ctx if ctx is done first.
+func (q *BlockingQueue[T]) Push(ctx context.Context, v T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.wait(ctx, q.notFull, q.hasRoom); err != nil {
+		return err
+	}
+	q.push(v)
+	return nil
+}
+
+// TryPush appends v to the queue without waiting, and reports false if the
+// queue is full or closed.
+func (q *BlockingQueue[T]) TryPush(v T) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || !q.hasRoom() {
+		return false
+	}
+	q.push(v)
+	return true
+}
+
+// Pop removes and returns the front element, waiting until there is one.
+// Elements pushed before Close are still returned, after which Pop returns
+// ErrClosed; it returns the error of ctx if ctx is done first.
+func (q *BlockingQueue[T]) Pop(ctx context.Context) (T, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.wait(ctx, q.notEmpty, q.hasElements); err != nil {
+		var zero T
+		return zero, err
+	}
+	return q.pop(), nil
+}
+
+// TryPop removes and returns the front element without waiting, and reports
+// false if the queue is empty.
+func (q *BlockingQueue[T]) TryPop() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.length == 0 {
+		var zero T
+		return zero, false
+	}
+	return q.pop(), true
+}
+
+// PopN removes and returns up to n front elements, waiting until there is at
+// least one, like Pop.
+func (q *BlockingQueue[T]) PopN(ctx context.Context, n int) ([]T, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.wait(ctx, q.notEmpty, q.hasElements); err != nil {
+		return nil, err
+	}
+	if n > q.length {
+		n = q.length
+	}
+	values := make([]T, n)
+	for i := range values {
+		values[i] = q.pop()
+	}
+	return values, nil
+}
+
+// Close closes the queue and wakes all waiting goroutines. Pushes fail after
+// Close, and pops fail once the remaining elements are taken. Closing a
+// closed queue has no effect.
+func (q *BlockingQueue[T]) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
+
+// Clear removes all elements.
+func (q *BlockingQueue[T]) Clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.buf, q.head, q.length = nil, 0, 0
+	q.notFull.Broadcast()
+}
+
+func (q *BlockingQueue[T]) hasRoom() bool {
+	return q.capacity == 0 || q.length < q.capacity
+}
+
+func (q *BlockingQueue[T]) hasElements() bool {
+	return q.length > 0
+}
+
+// wait waits on c until ready reports true, the queue is closed or ctx is
+// done. The caller must hold q.mu. Popping waiters see the elements left in
+// a closed queue, pushing waiters fail as soon as it is closed.
+func (q *BlockingQueue[T]) wait(ctx context.Context, c *sync.Cond, ready func() bool) error {
+	popping := c == q.notEmpty
+	if ready() && (popping || !q.closed) {
+		return nil
+	}
+	if done := ctx.Done(); done != nil {
+		// sync.Cond cannot wait on a channel, so wake the waiters when ctx
+		// is done.
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-done:
+				q.mu.Lock()
+				c.Broadcast()
+				q.mu.Unlock()
+			case <-stop:
+			}
+		}()
+	}
+	for {
+		if q.closed && (!popping || !ready()) {
+			return ErrClosed
+		}
+		if ready() {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.Wait()
+	}
+}
+
+// push appends v, the caller must hold q.mu and have checked hasRoom.
+func (q *BlockingQueue[T]) push(v T) {
+	if q.length == len(q.buf) {
+		q.grow()
+	}
+	q.buf[(q.head+q.length)%len(q.buf)] = v
+	q.length++
+	q.notEmpty.Signal()
+}
+
+// pop removes the front element, the caller must hold q.mu and have checked
+// hasElements.
+func (q *BlockingQueue[T]) pop() T {
+	var zero T
+	v := q.buf[q.head]
+	q.buf[q.head] = zero
+	q.head = (q.head + 1) % len(q.buf)
+	q.length--
+	q.notFull.Signal()
+	return v
+}
+
+// grow doubles the ring buffer, up to the capacity of a bounded queue.
+func (q *BlockingQueue[T]) grow() {
+	size := 2 * len(q.buf)
+	if size == 0 {
+		size = 8
+	}
+	if q.capacity > 0 && size > q.capacity {
+		size = q.capacity
+	}
+	buf := make([]T, size)
+	n := copy(buf, q.buf[q.head:])
+	copy(buf[n:], q.buf[:q.head])
+	q.buf, q.head = buf, 0
+}
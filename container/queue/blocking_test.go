@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBlockingQueueFIFO(t *testing.T) {
+	q := NewBlockingQueue[int](0)
+	if _, ok := q.TryPop(); ok {
+		t.Fatal("TryPop of an empty queue succeeded")
+	}
+	// Interleave pushes and pops so that the ring wraps while growing.
+	next, want := 0, 0
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 7; i++ {
+			q.TryPush(next)
+			next++
+		}
+		for i := 0; i < 5; i++ {
+			if v, ok := q.TryPop(); !ok || v != want {
+				t.Fatalf("TryPop = %d, %v, want %d", v, ok, want)
+			}
+			want++
+		}
+	}
+	if q.Length() != next-want {
+		t.Fatalf("Length = %d, want %d", q.Length(), next-want)
+	}
+	values, err := q.PopN(context.Background(), 3)
+	if err != nil || !reflect.DeepEqual(values, []int{want, want + 1, want + 2}) {
+		t.Fatalf("PopN(3) = %v, %v", values, err)
+	}
+	q.Clear()
+	if q.Length() != 0 {
+		t.Fatalf("Length after Clear = %d", q.Length())
+	}
+}
+
+func TestBlockingQueuePopWaits(t *testing.T) {
+	q := NewBlockingQueue[string](0)
+	got := make(chan string)
+	go func() {
+		v, _ := q.Pop(context.Background())
+		got <- v
+	}()
+	select {
+	case v := <-got:
+		t.Fatalf("Pop returned %q from an empty queue", v)
+	case <-time.After(10 * time.Millisecond):
+	}
+	q.Push(context.Background(), "a")
+	if v := <-got; v != "a" {
+		t.Fatalf("Pop = %q, want a", v)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Pop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Pop of an empty queue = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestBlockingQueueCapacity(t *testing.T) {
+	q := NewBlockingQueue[int](2)
+	if !q.TryPush(1) || !q.TryPush(2) || q.TryPush(3) {
+		t.Fatal("TryPush ignores the capacity")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Push(ctx, 3); err != context.DeadlineExceeded {
+		t.Fatalf("Push to a full queue = %v, want DeadlineExceeded", err)
+	}
+	done := make(chan error)
+	go func() { done <- q.Push(context.Background(), 3) }()
+	time.Sleep(10 * time.Millisecond)
+	q.TryPop()
+	if err := <-done; err != nil {
+		t.Fatalf("Push after a pop = %v", err)
+	}
+	values, _ := q.PopN(context.Background(), 5)
+	if !reflect.DeepEqual(values, []int{2, 3}) {
+		t.Fatalf("PopN = %v, want [2 3]", values)
+	}
+}
+
+func TestBlockingQueueClose(t *testing.T) {
+	q := NewBlockingQueue[int](1)
+	q.TryPush(1)
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- q.Push(context.Background(), 2)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	q.Close()
+	wg.Wait()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; !errors.Is(err, ErrClosed) {
+			t.Fatalf("Push blocked before Close = %v, want ErrClosed", err)
+		}
+	}
+	if q.TryPush(3) {
+		t.Fatal("TryPush to a closed queue succeeded")
+	}
+	if v, err := q.Pop(context.Background()); err != nil || v != 1 {
+		t.Fatalf("Pop of an element left in a closed queue = %d, %v", v, err)
+	}
+	if _, err := q.Pop(context.Background()); err != ErrClosed {
+		t.Fatalf("Pop of a closed empty queue = %v, want ErrClosed", err)
+	}
+	if _, err := q.PopN(context.Background(), 2); err != ErrClosed {
+		t.Fatalf("PopN of a closed empty queue = %v, want ErrClosed", err)
+	}
+}
+
+func TestBlockingQueueConcurrent(t *testing.T) {
+	const producers, perProducer = 4, 1000
+	q := NewBlockingQueue[int](16)
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				q.Push(context.Background(), p*perProducer+i)
+			}
+		}(p)
+	}
+	go func() {
+		wg.Wait()
+		q.Close()
+	}()
+	seen := make([]bool, producers*perProducer)
+	var mu sync.Mutex
+	var consumers sync.WaitGroup
+	for c := 0; c < 3; c++ {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			for {
+				values, err := q.PopN(context.Background(), 5)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				for _, v := range values {
+					seen[v] = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	consumers.Wait()
+	for v, ok := range seen {
+		if !ok {
+			t.Fatalf("value %d was not popped", v)
+		}
+	}
+}
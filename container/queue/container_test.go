@@ -0,0 +1,44 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/funbytes/modern-go/container"
+)
+
+func TestContainer(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+	for name, c := range map[string]container.Container{
+		"Queue":         NewQueue(false),
+		"Deque":         NewDeque[int](false),
+		"PriorityQueue": NewPriorityQueue[int](less, false),
+		"BlockingQueue": NewBlockingQueue[int](0),
+		"DelayQueue":    NewDelayQueue[int](),
+		"RingQueue":     NewRingQueue[int](4),
+		"LockFreeQueue": NewLockFreeQueue[int](),
+	} {
+		if !c.IsEmpty() || c.Len() != 0 {
+			t.Errorf("new %s is not empty", name)
+		}
+	}
+	if NewQueue(false).lock.IsSafe() || !NewQueue().lock.IsSafe() {
+		t.Fatal("the safe flag of Queue is not applied")
+	}
+	if NewDeque[int](false).lock.IsSafe() || NewPriorityQueue[int](less, false).lock.IsSafe() {
+		t.Fatal("the safe flag of Deque or PriorityQueue is not applied")
+	}
+
+	ring, lf := NewRingQueue[int](4), NewLockFreeQueue[int]()
+	for i := 0; i < 3; i++ {
+		ring.Push(i)
+		lf.Push(i)
+	}
+	if ring.Len() != 3 || lf.Len() != 3 || ring.IsEmpty() || lf.IsEmpty() {
+		t.Fatalf("Len = %d and %d after three pushes", ring.Len(), lf.Len())
+	}
+	ring.Clear()
+	lf.Clear()
+	if !ring.IsEmpty() || !lf.IsEmpty() {
+		t.Fatal("Clear left elements")
+	}
+}
@@ -0,0 +1,209 @@
+package queue
+
+import "github.com/funbytes/modern-go/internal/rwmutex"
+
+// DequeElement is the handle of an element in a Deque, returned by the
+// pushes and accepted by Remove, MoveToFront and MoveToBack. Unlike a
+// *list.Element its links are not exported, and a Deque rejects handles of
+// other deques or of removed elements.
+type DequeElement[T any] struct {
+	value      T
+	prev, next *DequeElement[T]
+	deque      *Deque[T] // nil once removed
+}
+
+// Value returns the value of the element.
+func (e *DequeElement[T]) Value() T {
+	return e.value
+}
+
+// Deque is a double-ended queue.
+type Deque[T any] struct {
+	lock   *rwmutex.RWMutex
+	root   DequeElement[T] // sentinel, root.next is the front
+	length int
+}
+
+// NewDeque creates and returns an empty Deque, which is concurrent-safe
+// unless safe is given as false.
+func NewDeque[T any](safe ...bool) *Deque[T] {
+	d := &Deque[T]{lock: rwmutex.NewDefaultSafe(safe...)}
+	d.root.next = &d.root
+	d.root.prev = &d.root
+	return d
+}
+
+// Length returns the number of elements in the deque.
+func (d *Deque[T]) Length() int {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.length
+}
+
+// Len returns the number of elements in the deque.
+func (d *Deque[T]) Len() int {
+	return d.Length()
+}
+
+// IsEmpty reports whether the deque has no elements.
+func (d *Deque[T]) IsEmpty() bool {
+	return d.Length() == 0
+}
+
+// PushFront inserts v at the front, and returns its handle.
+func (d *Deque[T]) PushFront(v T) *DequeElement[T] {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.insert(&DequeElement[T]{value: v}, &d.root)
+}
+
+// PushBack inserts v at the back, and returns its handle.
+func (d *Deque[T]) PushBack(v T) *DequeElement[T] {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.insert(&DequeElement[T]{value: v}, d.root.prev)
+}
+
+// PopFront removes and returns the front element, and reports false if the
+// deque is empty.
+func (d *Deque[T]) PopFront() (T, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.pop(d.root.next)
+}
+
+// PopBack removes and returns the back element, and reports false if the
+// deque is empty.
+func (d *Deque[T]) PopBack() (T, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.pop(d.root.prev)
+}
+
+// PeekFront returns the front element without removing it, and reports
+// false if the deque is empty.
+func (d *Deque[T]) PeekFront() (T, bool) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.root.next.value, d.length > 0
+}
+
+// PeekBack returns the back element without removing it, and reports false
+// if the deque is empty.
+func (d *Deque[T]) PeekBack() (T, bool) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.root.prev.value, d.length > 0
+}
+
+// Remove removes e from the deque, and reports false if e is not in it.
+func (d *Deque[T]) Remove(e *DequeElement[T]) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if e == nil || e.deque != d {
+		return false
+	}
+	d.remove(e)
+	return true
+}
+
+// MoveToFront moves e to the front, and reports false if e is not in the
+// deque.
+func (d *Deque[T]) MoveToFront(e *DequeElement[T]) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if e == nil || e.deque != d {
+		return false
+	}
+	if d.root.next != e {
+		d.insert(d.remove(e), &d.root)
+	}
+	return true
+}
+
+// MoveToBack moves e to the back, and reports false if e is not in the
+// deque.
+func (d *Deque[T]) MoveToBack(e *DequeElement[T]) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if e == nil || e.deque != d {
+		return false
+	}
+	if d.root.prev != e {
+		d.insert(d.remove(e), d.root.prev)
+	}
+	return true
+}
+
+// Values returns the elements from front to back.
+func (d *Deque[T]) Values() []T {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	values := make([]T, 0, d.length)
+	for e := d.root.next; e != &d.root; e = e.next {
+		values = append(values, e.value)
+	}
+	return values
+}
+
+// Range calls f for each element from front to back, until f returns false.
+// It iterates a snapshot taken before the first call, so f may modify the
+// deque.
+func (d *Deque[T]) Range(f func(v T) bool) {
+	for _, v := range d.Values() {
+		if !f(v) {
+			break
+		}
+	}
+}
+
+// Drain removes all elements and returns them from front to back.
+func (d *Deque[T]) Drain() []T {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	values := make([]T, 0, d.length)
+	for d.length > 0 {
+		v, _ := d.pop(d.root.next)
+		values = append(values, v)
+	}
+	return values
+}
+
+// Clear removes all elements, their handles become invalid.
+func (d *Deque[T]) Clear() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for d.length > 0 {
+		d.remove(d.root.next)
+	}
+}
+
+// insert links e after at, the caller must hold the lock.
+func (d *Deque[T]) insert(e, at *DequeElement[T]) *DequeElement[T] {
+	e.prev = at
+	e.next = at.next
+	e.prev.next = e
+	e.next.prev = e
+	e.deque = d
+	d.length++
+	return e
+}
+
+// remove unlinks e, the caller must hold the lock.
+func (d *Deque[T]) remove(e *DequeElement[T]) *DequeElement[T] {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.next, e.prev, e.deque = nil, nil, nil
+	d.length--
+	return e
+}
+
+// pop removes e and returns its value, or reports false if e is the
+// sentinel of an empty deque. The caller must hold the lock.
+func (d *Deque[T]) pop(e *DequeElement[T]) (T, bool) {
+	if e == &d.root {
+		var zero T
+		return zero, false
+	}
+	return d.remove(e).value, true
+}
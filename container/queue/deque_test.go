@@ -0,0 +1,119 @@
+package queue
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestDeque(t *testing.T) {
+	d := NewDeque[int]()
+	if _, ok := d.PopFront(); ok {
+		t.Fatal("PopFront of an empty deque succeeded")
+	}
+	if _, ok := d.PeekBack(); ok {
+		t.Fatal("PeekBack of an empty deque succeeded")
+	}
+	two := d.PushBack(2)
+	d.PushBack(3)
+	one := d.PushFront(1)
+	d.PushFront(0)
+	if v, _ := d.PeekFront(); v != 0 {
+		t.Fatalf("PeekFront = %d, want 0", v)
+	}
+	if v, _ := d.PeekBack(); v != 3 {
+		t.Fatalf("PeekBack = %d, want 3", v)
+	}
+	if !d.MoveToBack(one) || !d.MoveToFront(two) || !d.MoveToFront(two) {
+		t.Fatal("moving a handle of the deque failed")
+	}
+	if got := d.Values(); !reflect.DeepEqual(got, []int{2, 0, 3, 1}) {
+		t.Fatalf("Values = %v, want [2 0 3 1]", got)
+	}
+	if !d.Remove(two) || d.Remove(two) || d.MoveToFront(two) {
+		t.Fatal("a removed handle is still accepted")
+	}
+	if other := NewDeque[int](); other.Remove(one) || other.MoveToBack(one) {
+		t.Fatal("a handle of another deque is accepted")
+	}
+	if v, ok := d.PopBack(); !ok || v != 1 || one.Value() != 1 {
+		t.Fatalf("PopBack = %d, %v, want 1", v, ok)
+	}
+	if d.Remove(one) {
+		t.Fatal("the handle of a popped element is still accepted")
+	}
+
+	// Range iterates a snapshot, so it may modify the deque.
+	var seen []int
+	d.Range(func(v int) bool {
+		seen = append(seen, v)
+		d.PushBack(v + 10)
+		return true
+	})
+	if !reflect.DeepEqual(seen, []int{0, 3}) {
+		t.Fatalf("Range = %v, want [0 3]", seen)
+	}
+	if got := d.Drain(); !reflect.DeepEqual(got, []int{0, 3, 10, 13}) || d.Length() != 0 {
+		t.Fatalf("Drain = %v, Length = %d", got, d.Length())
+	}
+	e := d.PushBack(1)
+	d.Clear()
+	if d.Length() != 0 || d.Remove(e) {
+		t.Fatal("Clear left elements or valid handles")
+	}
+}
+
+// TestDequeLRU uses a Deque as the recency list of an LRU cache.
+func TestDequeLRU(t *testing.T) {
+	const capacity = 3
+	d := NewDeque[string]()
+	index := make(map[string]*DequeElement[string])
+	touch := func(key string) {
+		if e, ok := index[key]; ok {
+			d.MoveToFront(e)
+			return
+		}
+		index[key] = d.PushFront(key)
+		if d.Length() > capacity {
+			evicted, _ := d.PopBack()
+			delete(index, evicted)
+		}
+	}
+	for _, key := range []string{"a", "b", "c", "a", "d", "b", "e"} {
+		touch(key)
+	}
+	if got := d.Values(); !reflect.DeepEqual(got, []string{"e", "b", "d"}) {
+		t.Fatalf("recency = %v, want [e b d]", got)
+	}
+}
+
+func TestDequeConcurrent(t *testing.T) {
+	d := NewDeque[int]()
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				e := d.PushBack(i)
+				if i%2 == 0 {
+					d.MoveToFront(e)
+				}
+				if i%3 == 0 {
+					d.Remove(e)
+				}
+				if w%2 == 0 {
+					d.PopFront()
+				} else {
+					d.PopBack()
+				}
+				d.Range(func(int) bool { return true })
+			}
+		}(w)
+	}
+	wg.Wait()
+	d.Drain()
+	if d.Length() != 0 {
+		t.Fatalf("Length = %d after Drain", d.Length())
+	}
+}
@@ -0,0 +1,568 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyncPolicy tells when the segments of a DurableQueue are flushed to stable
+// storage.
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs the active segment before every Push and Ack
+	// returns, so that they survive a crash of the machine.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval fsyncs the active segment every
+	// DurableOptions.SyncInterval from a background goroutine.
+	SyncInterval
+	// SyncNone leaves flushing to the operating system, so that writes
+	// survive a crash of the process only. Call Sync to flush.
+	SyncNone
+)
+
+// Every segment record is an op byte, the uvarint message id, for opPush
+// the uvarint payload length and the payload, and the IEEE CRC-32 of the
+// preceding bytes of the record. Every segment starts with an opNextID
+// record holding the next id to push, so that ids are not reused once the
+// segments of acknowledged messages are deleted.
+const (
+	opPush   byte = 'p'
+	opAck    byte = 'a'
+	opNextID byte = 'n'
+)
+
+const segmentExt = ".seg"
+
+var (
+	// ErrNotInFlight is returned by Ack and Nack for an id which is not
+	// delivered and unacknowledged.
+	ErrNotInFlight = errors.New("queue: message not in flight")
+	// ErrCorrupt is returned by OpenDurable for a damaged segment other
+	// than the last one.
+	ErrCorrupt = errors.New("queue: corrupt segment")
+)
+
+// DurableOptions configures a DurableQueue, zero fields take the defaults.
+type DurableOptions struct {
+	// SegmentSize is the size after which a new segment file is started,
+	// 64 MiB by default.
+	SegmentSize int64
+	// Sync is the fsync policy, SyncAlways by default.
+	Sync SyncPolicy
+	// SyncInterval is the period of SyncInterval, 1 second by default.
+	SyncInterval time.Duration
+	// VisibilityTimeout is how long a popped message stays invisible
+	// before it is delivered again unless acknowledged, 30 seconds by
+	// default.
+	VisibilityTimeout time.Duration
+}
+
+// Message is a message popped from a DurableQueue.
+type Message struct {
+	ID      uint64
+	Payload []byte
+	// Deliveries counts the deliveries of the message since the queue was
+	// opened, including this one.
+	Deliveries int
+}
+
+type durableMessage struct {
+	id         uint64
+	payload    []byte
+	segment    *segment
+	deliveries int
+	inFlight   *PriorityItem[*durableMessage] // nil unless delivered
+	visibleAt  time.Time
+}
+
+// message returns m as delivered, with a copy of its payload so that the
+// caller cannot change the payload of a later delivery.
+func (m *durableMessage) message() Message {
+	return Message{ID: m.id, Payload: append([]byte(nil), m.payload...), Deliveries: m.deliveries}
+}
+
+type segment struct {
+	seq     uint64 // names the file, increasing with every rotation
+	pending int    // unacknowledged messages pushed into it
+}
+
+// DurableQueue is a FIFO queue of byte messages persisted in a directory of
+// append-only segment files, with at-least-once delivery: a popped message
+// is delivered again if it is not acknowledged within the visibility
+// timeout, or after the queue is reopened. Segments whose messages are all
+// acknowledged are deleted. It is safe for concurrent use.
+type DurableQueue struct {
+	lock     sync.Mutex
+	dir      string
+	opts     DurableOptions
+	f        *os.File // active segment, the last of segments
+	size     int64    // size of f
+	buf      []byte
+	segments []*segment
+	messages map[uint64]*durableMessage
+	ready    []*durableMessage // FIFO of messages to deliver
+	inFlight itemHeap[*durableMessage]
+	nextID   uint64
+	closed   bool
+	// wake is closed when a message becomes ready, to wake Take.
+	wake chan struct{}
+	stop chan struct{}
+	now  func() time.Time
+}
+
+// OpenDurable opens the queue stored in dir, creating dir if needed, and
+// recovers its unacknowledged messages in push order. Messages which were
+// in flight are delivered again. A record torn by a crash at the end of
+// the last segment is discarded.
+func OpenDurable(dir string, opts DurableOptions) (*DurableQueue, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = 64 << 20
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = time.Second
+	}
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = 30 * time.Second
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	q := &DurableQueue{
+		dir:      dir,
+		opts:     opts,
+		messages: make(map[uint64]*durableMessage),
+		nextID:   1,
+		now:      time.Now,
+	}
+	q.inFlight.less = func(a, b *durableMessage) bool { return a.visibleAt.Before(b.visibleAt) }
+	if err := q.recover(); err != nil {
+		if q.f != nil {
+			q.f.Close()
+		}
+		return nil, err
+	}
+	if opts.Sync == SyncInterval {
+		q.stop = make(chan struct{})
+		go q.syncLoop()
+	}
+	return q, nil
+}
+
+func (q *DurableQueue) segmentPath(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
+
+// recover replays the segments of q.dir and opens the last one for append.
+func (q *DurableQueue) recover() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return err
+	}
+	var seqs []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		if seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64); err == nil {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	for i, seq := range seqs {
+		seg := &segment{seq: seq}
+		q.segments = append(q.segments, seg)
+		data, err := os.ReadFile(q.segmentPath(seq))
+		if err != nil {
+			return err
+		}
+		end := q.replay(data, seg)
+		last := i == len(seqs)-1
+		if end < len(data) && !last {
+			return fmt.Errorf("%w: %s", ErrCorrupt, q.segmentPath(seq))
+		}
+		if last {
+			if err := q.openSegment(seg, int64(end)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, m := range q.messages {
+		q.ready = append(q.ready, m)
+	}
+	sort.Slice(q.ready, func(i, j int) bool { return q.ready[i].id < q.ready[j].id })
+	if q.f == nil {
+		return q.rotate()
+	}
+	return q.removeAcked()
+}
+
+// replay applies the records of data and returns the offset after the last
+// complete record.
+func (q *DurableQueue) replay(data []byte, seg *segment) int {
+	end := 0
+	for end < len(data) {
+		rec := data[end:]
+		op := rec[0]
+		id, n := binary.Uvarint(rec[1:])
+		if n <= 0 || (op != opPush && op != opAck && op != opNextID) {
+			return end
+		}
+		off := 1 + n
+		var payload []byte
+		if op == opPush {
+			size, n := binary.Uvarint(rec[off:])
+			if n <= 0 || size > uint64(len(rec)-off-n) {
+				return end
+			}
+			off += n
+			payload = append([]byte(nil), rec[off:off+int(size)]...)
+			off += int(size)
+		}
+		if len(rec)-off < 4 || binary.LittleEndian.Uint32(rec[off:]) != crc32.ChecksumIEEE(rec[:off]) {
+			return end
+		}
+		end += off + 4
+		switch op {
+		case opPush:
+			q.messages[id] = &durableMessage{id: id, payload: payload, segment: seg}
+			seg.pending++
+			if id >= q.nextID {
+				q.nextID = id + 1
+			}
+		case opAck:
+			if m, ok := q.messages[id]; ok {
+				delete(q.messages, id)
+				m.segment.pending--
+			}
+		case opNextID:
+			if id > q.nextID {
+				q.nextID = id
+			}
+		}
+	}
+	return end
+}
+
+// openSegment opens the file of seg for append, truncated to size.
+func (q *DurableQueue) openSegment(seg *segment, size int64) error {
+	f, err := os.OpenFile(q.segmentPath(seg.seq), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(size, 0); err != nil {
+		f.Close()
+		return err
+	}
+	q.f, q.size = f, size
+	return nil
+}
+
+// rotate closes the active segment and starts a new one.
+func (q *DurableQueue) rotate() error {
+	if q.f != nil {
+		if err := q.f.Sync(); err != nil {
+			return err
+		}
+		if err := q.f.Close(); err != nil {
+			return err
+		}
+		q.f = nil
+	}
+	seg := &segment{seq: 1}
+	if n := len(q.segments); n > 0 {
+		seg.seq = q.segments[n-1].seq + 1
+	}
+	if err := q.openSegment(seg, 0); err != nil {
+		return err
+	}
+	q.segments = append(q.segments, seg)
+	if err := q.write(opNextID, q.nextID, nil); err != nil {
+		return err
+	}
+	if err := syncDir(q.dir); err != nil {
+		return err
+	}
+	return q.removeAcked()
+}
+
+// removeAcked deletes the oldest segments while all their messages are
+// acknowledged, keeping the active one. Only a prefix is deleted, as a
+// segment can hold the acks of messages of the segments before it.
+func (q *DurableQueue) removeAcked() error {
+	for len(q.segments) > 1 && q.segments[0].pending == 0 {
+		if err := os.Remove(q.segmentPath(q.segments[0].seq)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		q.segments = q.segments[1:]
+	}
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// write appends a record to the active segment, the caller must hold the
+// lock.
+func (q *DurableQueue) write(op byte, id uint64, payload []byte) error {
+	if q.closed {
+		return ErrClosed
+	}
+	b := append(q.buf[:0], op)
+	b = appendUvarint(b, id)
+	if op == opPush {
+		b = appendUvarint(b, uint64(len(payload)))
+		b = append(b, payload...)
+	}
+	var sum [4]byte
+	binary.LittleEndian.PutUint32(sum[:], crc32.ChecksumIEEE(b))
+	b = append(b, sum[:]...)
+	q.buf = b
+	if _, err := q.f.Write(b); err != nil {
+		// Drop the partial record, so that later ones stay readable.
+		q.f.Truncate(q.size)
+		q.f.Seek(q.size, 0)
+		return err
+	}
+	q.size += int64(len(b))
+	if q.opts.Sync == SyncAlways {
+		return q.f.Sync()
+	}
+	return nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// Push appends a copy of payload to the queue, and returns its id. If Push
+// fails to flush the record, the message may still be delivered after the
+// queue is reopened.
+func (q *DurableQueue) Push(payload []byte) (uint64, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if !q.closed && q.size >= q.opts.SegmentSize {
+		if err := q.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	// Take the id even if the write fails, as a record which failed to
+	// sync may still be on disk.
+	id := q.nextID
+	q.nextID++
+	if err := q.write(opPush, id, payload); err != nil {
+		return 0, err
+	}
+	seg := q.segments[len(q.segments)-1]
+	seg.pending++
+	m := &durableMessage{id: id, payload: append([]byte(nil), payload...), segment: seg}
+	q.messages[id] = m
+	q.ready = append(q.ready, m)
+	q.notify()
+	return id, nil
+}
+
+// Pop delivers the next message without waiting, and reports false if no
+// message is ready. The message becomes invisible for the visibility
+// timeout, and is delivered again unless acknowledged with Ack before.
+func (q *DurableQueue) Pop() (Message, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	m, _ := q.pop()
+	if m == nil {
+		return Message{}, false
+	}
+	return m.message(), true
+}
+
+// Take delivers the next message like Pop, waiting until one is ready. It
+// returns ErrClosed once the queue is closed, or the error of ctx if ctx is
+// done first.
+func (q *DurableQueue) Take(ctx context.Context) (Message, error) {
+	for {
+		q.lock.Lock()
+		if q.closed {
+			q.lock.Unlock()
+			return Message{}, ErrClosed
+		}
+		m, wait := q.pop()
+		if m != nil {
+			q.lock.Unlock()
+			return m.message(), nil
+		}
+		if q.wake == nil {
+			q.wake = make(chan struct{})
+		}
+		wake := q.wake
+		q.lock.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+		select {
+		case <-wake:
+		case <-expired:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return Message{}, err
+		}
+	}
+}
+
+// pop delivers the next ready message, after making ready the in-flight
+// messages whose visibility timeout expired. Without a ready message it
+// returns the time until the next expiry, or 0 if none is in flight. The
+// caller must hold the lock.
+func (q *DurableQueue) pop() (*durableMessage, time.Duration) {
+	now := q.now()
+	for q.inFlight.Len() > 0 && !q.inFlight.items[0].value.visibleAt.After(now) {
+		m := heap.Pop(&q.inFlight).(*PriorityItem[*durableMessage]).value
+		m.inFlight = nil
+		q.ready = append(q.ready, m)
+	}
+	if len(q.ready) == 0 {
+		if q.inFlight.Len() == 0 {
+			return nil, 0
+		}
+		return nil, q.inFlight.items[0].value.visibleAt.Sub(now)
+	}
+	m := q.ready[0]
+	q.ready[0] = nil
+	q.ready = q.ready[1:]
+	m.deliveries++
+	m.visibleAt = now.Add(q.opts.VisibilityTimeout)
+	m.inFlight = &PriorityItem[*durableMessage]{value: m, owner: q}
+	heap.Push(&q.inFlight, m.inFlight)
+	return m, 0
+}
+
+// Ack acknowledges the in-flight message id, which is then never delivered
+// again.
+func (q *DurableQueue) Ack(id uint64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	m, ok := q.messages[id]
+	if !ok || m.inFlight == nil {
+		return ErrNotInFlight
+	}
+	if err := q.write(opAck, id, nil); err != nil {
+		return err
+	}
+	heap.Remove(&q.inFlight, m.inFlight.index)
+	m.inFlight = nil
+	delete(q.messages, id)
+	m.segment.pending--
+	return q.removeAcked()
+}
+
+// Nack gives back the in-flight message id, which is ready again
+// immediately, at the back of the queue.
+func (q *DurableQueue) Nack(id uint64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	m, ok := q.messages[id]
+	if !ok || m.inFlight == nil {
+		return ErrNotInFlight
+	}
+	heap.Remove(&q.inFlight, m.inFlight.index)
+	m.inFlight = nil
+	q.ready = append(q.ready, m)
+	q.notify()
+	return nil
+}
+
+// notify wakes the goroutines waiting in Take, the caller must hold the lock.
+func (q *DurableQueue) notify() {
+	if q.wake != nil {
+		close(q.wake)
+		q.wake = nil
+	}
+}
+
+// Length returns the number of unacknowledged messages, ready or in flight.
+func (q *DurableQueue) Length() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.messages)
+}
+
+// InFlight returns the number of delivered and unacknowledged messages.
+func (q *DurableQueue) InFlight() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.inFlight.Len()
+}
+
+// Sync flushes the active segment to stable storage.
+func (q *DurableQueue) Sync() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.closed {
+		return ErrClosed
+	}
+	return q.f.Sync()
+}
+
+func (q *DurableQueue) syncLoop() {
+	ticker := time.NewTicker(q.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			q.Sync()
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// Close flushes and closes the active segment, and wakes the goroutines
+// waiting in Take. Unacknowledged messages are delivered again when the
+// queue is reopened.
+func (q *DurableQueue) Close() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	q.notify()
+	if q.stop != nil {
+		close(q.stop)
+	}
+	err := q.f.Sync()
+	if cerr := q.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,265 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openDurable(t *testing.T, dir string, opts DurableOptions) *DurableQueue {
+	t.Helper()
+	q, err := OpenDurable(dir, opts)
+	if err != nil {
+		t.Fatalf("OpenDurable: %v", err)
+	}
+	return q
+}
+
+// popAll pops every ready message and returns their payloads.
+func popAll(q *DurableQueue) []string {
+	var payloads []string
+	for {
+		m, ok := q.Pop()
+		if !ok {
+			return payloads
+		}
+		payloads = append(payloads, string(m.Payload))
+	}
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestDurableAckNack(t *testing.T) {
+	q := openDurable(t, t.TempDir(), DurableOptions{})
+	defer q.Close()
+	for _, s := range []string{"a", "b", "c"} {
+		if _, err := q.Push([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, _ := q.Pop()
+	b, _ := q.Pop()
+	if string(a.Payload) != "a" || string(b.Payload) != "b" || q.InFlight() != 2 {
+		t.Fatalf("Pop = %q, %q with %d in flight", a.Payload, b.Payload, q.InFlight())
+	}
+	if err := q.Ack(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Ack(a.ID); !errors.Is(err, ErrNotInFlight) {
+		t.Fatalf("second Ack = %v, want ErrNotInFlight", err)
+	}
+	if err := q.Nack(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := q.Pop()
+	again, _ := q.Pop()
+	if string(c.Payload) != "c" || again.ID != b.ID || again.Deliveries != 2 {
+		t.Fatalf("after Nack popped %q then %+v, want c then b delivered twice", c.Payload, again)
+	}
+	if err := q.Nack(12345); !errors.Is(err, ErrNotInFlight) {
+		t.Fatalf("Nack of an unknown id = %v, want ErrNotInFlight", err)
+	}
+	if q.Length() != 2 {
+		t.Fatalf("Length = %d, want 2", q.Length())
+	}
+}
+
+func TestDurableVisibilityTimeout(t *testing.T) {
+	q := openDurable(t, t.TempDir(), DurableOptions{VisibilityTimeout: time.Minute})
+	defer q.Close()
+	now := time.Unix(1000, 0)
+	q.now = func() time.Time { return now }
+	q.Push([]byte("a"))
+	m, _ := q.Pop()
+	if _, ok := q.Pop(); ok {
+		t.Fatal("an in-flight message was delivered again before its timeout")
+	}
+	now = now.Add(time.Minute)
+	again, ok := q.Pop()
+	if !ok || again.ID != m.ID || again.Deliveries != 2 {
+		t.Fatalf("Pop after the timeout = %+v, %v", again, ok)
+	}
+	if err := q.Ack(m.ID); err != nil {
+		t.Fatalf("Ack of the redelivered message: %v", err)
+	}
+}
+
+func TestDurableRecovery(t *testing.T) {
+	dir := t.TempDir()
+	q := openDurable(t, dir, DurableOptions{Sync: SyncNone})
+	for i := 0; i < 5; i++ {
+		q.Push([]byte(fmt.Sprint(i)))
+	}
+	m0, _ := q.Pop()
+	// The second message stays in flight and must be delivered again
+	// after reopening.
+	q.Pop()
+	q.Ack(m0.ID)
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Push([]byte("x")); err != ErrClosed {
+		t.Fatalf("Push to a closed queue = %v, want ErrClosed", err)
+	}
+
+	q = openDurable(t, dir, DurableOptions{})
+	id, _ := q.Push([]byte("5"))
+	if id != 6 {
+		t.Fatalf("Push after reopening returned id %d, want 6", id)
+	}
+	if got := fmt.Sprint(popAll(q)); got != "[1 2 3 4 5]" {
+		t.Fatalf("recovered %s, want [1 2 3 4 5]", got)
+	}
+	q.Close()
+}
+
+func TestDurableTornTail(t *testing.T) {
+	dir := t.TempDir()
+	q := openDurable(t, dir, DurableOptions{})
+	q.Push([]byte("a"))
+	q.Push([]byte("bb"))
+	q.Close()
+	files := segmentFiles(t, dir)
+	info, _ := os.Stat(files[0])
+	// Cut the last record short, as a crash in the middle of a write would.
+	if err := os.Truncate(files[0], info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+	q = openDurable(t, dir, DurableOptions{})
+	q.Push([]byte("c"))
+	q.Close()
+	q = openDurable(t, dir, DurableOptions{})
+	defer q.Close()
+	if got := fmt.Sprint(popAll(q)); got != "[a c]" {
+		t.Fatalf("recovered %s, want [a c]", got)
+	}
+}
+
+func TestDurableSegments(t *testing.T) {
+	dir := t.TempDir()
+	q := openDurable(t, dir, DurableOptions{SegmentSize: 64})
+	for i := 0; i < 50; i++ {
+		q.Push([]byte(fmt.Sprintf("message %02d", i)))
+	}
+	if n := len(segmentFiles(t, dir)); n < 5 {
+		t.Fatalf("%d segments for 50 messages of 64 byte segments", n)
+	}
+	// Ack all but the last ten messages.
+	for i := 0; i < 40; i++ {
+		m, _ := q.Pop()
+		q.Ack(m.ID)
+	}
+	q.Close()
+	q = openDurable(t, dir, DurableOptions{SegmentSize: 64})
+	if q.Length() != 10 {
+		t.Fatalf("Length after reopening = %d, want 10", q.Length())
+	}
+	for i := 40; i < 50; i++ {
+		m, _ := q.Pop()
+		if want := fmt.Sprintf("message %02d", i); string(m.Payload) != want {
+			t.Fatalf("Pop = %q, want %q", m.Payload, want)
+		}
+		q.Ack(m.ID)
+	}
+	if n := len(segmentFiles(t, dir)); n != 1 {
+		t.Fatalf("%d segments left after acking everything, want the active one", n)
+	}
+	q.Close()
+	q = openDurable(t, dir, DurableOptions{})
+	defer q.Close()
+	if q.Length() != 0 {
+		t.Fatalf("Length = %d after acking everything", q.Length())
+	}
+}
+
+func TestDurableIDsNotReused(t *testing.T) {
+	dir := t.TempDir()
+	q := openDurable(t, dir, DurableOptions{SegmentSize: 16})
+	q.Push([]byte("0123456789"))
+	m, _ := q.Pop()
+	q.Ack(m.ID)
+	// The second push starts a new segment, and deletes the first one as
+	// its message is acknowledged.
+	q.Push([]byte("0123456789"))
+	q.Close()
+	files := segmentFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("%d segments, want 1", len(files))
+	}
+	// Tear the second push, leaving no push record on disk.
+	info, _ := os.Stat(files[0])
+	if err := os.Truncate(files[0], info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	q = openDurable(t, dir, DurableOptions{})
+	defer q.Close()
+	if id, _ := q.Push([]byte("x")); id <= m.ID {
+		t.Fatalf("Push after reopening returned id %d, reusing the acknowledged id %d", id, m.ID)
+	}
+}
+
+func TestDurablePayloadCopy(t *testing.T) {
+	q := openDurable(t, t.TempDir(), DurableOptions{VisibilityTimeout: time.Minute})
+	defer q.Close()
+	now := time.Unix(1000, 0)
+	q.now = func() time.Time { return now }
+	q.Push([]byte("abc"))
+	m, _ := q.Pop()
+	m.Payload[0] = 'x'
+	q.Nack(m.ID)
+	if again, _ := q.Pop(); string(again.Payload) != "abc" {
+		t.Fatalf("redelivered %q after changing the payload of a delivery, want abc", again.Payload)
+	}
+}
+
+func TestDurableCorruptSegment(t *testing.T) {
+	dir := t.TempDir()
+	q := openDurable(t, dir, DurableOptions{SegmentSize: 16})
+	for i := 0; i < 5; i++ {
+		q.Push([]byte("0123456789"))
+	}
+	q.Close()
+	files := segmentFiles(t, dir)
+	data, _ := os.ReadFile(files[0])
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(files[0], data, 0644)
+	if _, err := OpenDurable(dir, DurableOptions{}); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("OpenDurable with a damaged first segment = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestDurableTake(t *testing.T) {
+	q := openDurable(t, t.TempDir(), DurableOptions{Sync: SyncInterval, SyncInterval: time.Millisecond})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Push([]byte("a"))
+	}()
+	m, err := q.Take(context.Background())
+	if err != nil || string(m.Payload) != "a" {
+		t.Fatalf("Take = %q, %v", m.Payload, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Take(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Take of an empty queue = %v, want DeadlineExceeded", err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Close()
+	}()
+	if _, err := q.Take(context.Background()); err != ErrClosed {
+		t.Fatalf("Take on Close = %v, want ErrClosed", err)
+	}
+}
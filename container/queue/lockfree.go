@@ -0,0 +1,243 @@
+package queue
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// cacheLinePad keeps the fields around it on different cache lines, so that
+// producers and consumers do not invalidate each other's line.
+type cacheLinePad [64]byte
+
+// RingQueue is a bounded lock-free multi-producer multi-consumer FIFO queue,
+// after Dmitry Vyukov's array queue. Each cell carries a sequence number
+// which tells producers and consumers whether it is free or filled for
+// their lap, so a Push or Pop is a single CAS on a shared counter. It
+// does not allocate after NewRingQueue.
+type RingQueue[T any] struct {
+	// enqueue and dequeue are accessed atomically, and at offsets multiple
+	// of 8 from the start so that they are 64-bit aligned on 32-bit platforms.
+	enqueue uint64
+	_       cacheLinePad
+	dequeue uint64
+	_       cacheLinePad
+	mask    uint64
+	// The sequence numbers are kept apart from the values, as a cell of
+	// both would leave every other sequence number misaligned on 32-bit
+	// platforms when the size of T is not a multiple of 8.
+	seqs   []uint64
+	values []T
+	_      cacheLinePad
+}
+
+// NewRingQueue creates and returns an empty RingQueue holding at most
+// capacity elements, rounded up to a power of two of at least 2: with a
+// single cell, a filled cell would look free to the producers of the next
+// lap.
+func NewRingQueue[T any](capacity int) *RingQueue[T] {
+	size := 2
+	for size < capacity {
+		size <<= 1
+	}
+	q := &RingQueue[T]{mask: uint64(size - 1), seqs: make([]uint64, size), values: make([]T, size)}
+	for i := range q.seqs {
+		q.seqs[i] = uint64(i)
+	}
+	return q
+}
+
+// Cap returns the capacity of the queue.
+func (q *RingQueue[T]) Cap() int {
+	return len(q.seqs)
+}
+
+// Length returns the number of elements in the queue, which is only a hint
+// while other goroutines push or pop.
+func (q *RingQueue[T]) Length() int {
+	dequeue := atomic.LoadUint64(&q.dequeue)
+	n := int64(atomic.LoadUint64(&q.enqueue) - dequeue)
+	if n < 0 {
+		return 0
+	}
+	if n > int64(len(q.seqs)) {
+		return len(q.seqs)
+	}
+	return int(n)
+}
+
+// Len returns the number of elements in the queue, like Length.
+func (q *RingQueue[T]) Len() int {
+	return q.Length()
+}
+
+// IsEmpty reports whether the queue has no elements, like Length.
+func (q *RingQueue[T]) IsEmpty() bool {
+	return q.Length() == 0
+}
+
+// Clear pops all elements.
+func (q *RingQueue[T]) Clear() {
+	for {
+		if _, ok := q.Pop(); !ok {
+			return
+		}
+	}
+}
+
+// Push appends v to the queue, and reports false if the queue is full.
+func (q *RingQueue[T]) Push(v T) bool {
+	pos := atomic.LoadUint64(&q.enqueue)
+	for {
+		i := pos & q.mask
+		seq := atomic.LoadUint64(&q.seqs[i])
+		switch dif := int64(seq - pos); {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&q.enqueue, pos, pos+1) {
+				q.values[i] = v
+				atomic.StoreUint64(&q.seqs[i], pos+1)
+				return true
+			}
+		case dif < 0:
+			// The cell still holds the element of the previous lap.
+			return false
+		}
+		pos = atomic.LoadUint64(&q.enqueue)
+	}
+}
+
+// Pop removes and returns the front element, and reports false if the queue
+// is empty.
+func (q *RingQueue[T]) Pop() (T, bool) {
+	pos := atomic.LoadUint64(&q.dequeue)
+	for {
+		i := pos & q.mask
+		seq := atomic.LoadUint64(&q.seqs[i])
+		switch dif := int64(seq - (pos + 1)); {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&q.dequeue, pos, pos+1) {
+				var zero T
+				v := q.values[i]
+				q.values[i] = zero
+				atomic.StoreUint64(&q.seqs[i], pos+q.mask+1)
+				return v, true
+			}
+		case dif < 0:
+			// The cell is not filled for this lap yet.
+			var zero T
+			return zero, false
+		}
+		pos = atomic.LoadUint64(&q.dequeue)
+	}
+}
+
+type lfNode[T any] struct {
+	value T
+	next  unsafe.Pointer // *lfNode[T]
+}
+
+func loadNode[T any](p *unsafe.Pointer) *lfNode[T] {
+	return (*lfNode[T])(atomic.LoadPointer(p))
+}
+
+func casNode[T any](p *unsafe.Pointer, old, x *lfNode[T]) bool {
+	return atomic.CompareAndSwapPointer(p, unsafe.Pointer(old), unsafe.Pointer(x))
+}
+
+// LockFreeQueue is an unbounded lock-free multi-producer multi-consumer FIFO
+// queue, after the Michael-Scott linked queue. The garbage collector keeps a
+// node alive while any goroutine still reads it, so it is free of ABA.
+type LockFreeQueue[T any] struct {
+	// length is accessed atomically, and first so that it is 64-bit aligned
+	// on 32-bit platforms.
+	length int64
+	_      cacheLinePad
+	head   unsafe.Pointer // *lfNode[T], a dummy before the front element
+	_      cacheLinePad
+	tail   unsafe.Pointer // *lfNode[T], the last node or one before it
+	_      cacheLinePad
+}
+
+// NewLockFreeQueue creates and returns an empty LockFreeQueue.
+func NewLockFreeQueue[T any]() *LockFreeQueue[T] {
+	dummy := unsafe.Pointer(&lfNode[T]{})
+	return &LockFreeQueue[T]{head: dummy, tail: dummy}
+}
+
+// Length returns the number of elements in the queue, which is only a hint
+// while other goroutines push or pop.
+func (q *LockFreeQueue[T]) Length() int {
+	if n := atomic.LoadInt64(&q.length); n > 0 {
+		return int(n)
+	}
+	return 0
+}
+
+// Len returns the number of elements in the queue, like Length.
+func (q *LockFreeQueue[T]) Len() int {
+	return q.Length()
+}
+
+// IsEmpty reports whether the queue has no elements.
+func (q *LockFreeQueue[T]) IsEmpty() bool {
+	head := loadNode[T](&q.head)
+	return loadNode[T](&head.next) == nil
+}
+
+// Clear pops all elements.
+func (q *LockFreeQueue[T]) Clear() {
+	for {
+		if _, ok := q.Pop(); !ok {
+			return
+		}
+	}
+}
+
+// Push appends v to the queue.
+func (q *LockFreeQueue[T]) Push(v T) {
+	n := &lfNode[T]{value: v}
+	for {
+		tail := loadNode[T](&q.tail)
+		next := loadNode[T](&tail.next)
+		if tail != loadNode[T](&q.tail) {
+			continue
+		}
+		if next != nil {
+			// Help the pusher which linked next to swing the tail.
+			casNode(&q.tail, tail, next)
+			continue
+		}
+		if casNode(&tail.next, nil, n) {
+			casNode(&q.tail, tail, n)
+			atomic.AddInt64(&q.length, 1)
+			return
+		}
+	}
+}
+
+// Pop removes and returns the front element, and reports false if the queue
+// is empty.
+func (q *LockFreeQueue[T]) Pop() (T, bool) {
+	for {
+		head := loadNode[T](&q.head)
+		tail := loadNode[T](&q.tail)
+		next := loadNode[T](&head.next)
+		if head != loadNode[T](&q.head) {
+			continue
+		}
+		if next == nil {
+			var zero T
+			return zero, false
+		}
+		if head == tail {
+			casNode(&q.tail, tail, next)
+			continue
+		}
+		// next becomes the dummy and keeps its value until the next Pop, as
+		// other poppers may still be reading it.
+		v := next.value
+		if casNode(&q.head, head, next) {
+			atomic.AddInt64(&q.length, -1)
+			return v, true
+		}
+	}
+}
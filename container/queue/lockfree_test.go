@@ -0,0 +1,181 @@
+package queue
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// fifo is the surface shared by the queues under test.
+type fifo interface {
+	Push(v int)
+	Pop() (int, bool)
+	Length() int
+}
+
+// ringFIFO adapts RingQueue to fifo, spinning while it is full.
+type ringFIFO struct{ *RingQueue[int] }
+
+func (q ringFIFO) Push(v int) {
+	for !q.RingQueue.Push(v) {
+		runtime.Gosched()
+	}
+}
+
+// listFIFO adapts Queue to fifo.
+type listFIFO struct{ *Queue }
+
+func (q listFIFO) Push(v int) { q.Queue.Push(v) }
+
+func (q listFIFO) Pop() (int, bool) {
+	v := q.Queue.Pop()
+	if v == nil {
+		return 0, false
+	}
+	return v.(int), true
+}
+
+func fifos() map[string]func() fifo {
+	return map[string]func() fifo{
+		"Queue":         func() fifo { return listFIFO{NewQueue()} },
+		"RingQueue":     func() fifo { return ringFIFO{NewRingQueue[int](1024)} },
+		"LockFreeQueue": func() fifo { return NewLockFreeQueue[int]() },
+	}
+}
+
+func TestRingQueueBounds(t *testing.T) {
+	for _, c := range []struct{ capacity, want int }{{-1, 2}, {0, 2}, {1, 2}, {2, 2}, {3, 4}, {8, 8}} {
+		if got := NewRingQueue[int](c.capacity).Cap(); got != c.want {
+			t.Errorf("Cap of NewRingQueue(%d) = %d, want %d", c.capacity, got, c.want)
+		}
+	}
+	q := NewRingQueue[int](5)
+	if q.Cap() != 8 {
+		t.Fatalf("Cap = %d, want 8", q.Cap())
+	}
+	for lap := 0; lap < 3; lap++ {
+		for i := 0; i < 8; i++ {
+			if !q.Push(i) {
+				t.Fatalf("Push(%d) to a queue of %d elements failed", i, q.Length())
+			}
+		}
+		if q.Push(8) || q.Length() != 8 {
+			t.Fatalf("Push to a full queue succeeded, Length = %d", q.Length())
+		}
+		for i := 0; i < 8; i++ {
+			if v, ok := q.Pop(); !ok || v != i {
+				t.Fatalf("Pop = %d, %v, want %d", v, ok, i)
+			}
+		}
+		if _, ok := q.Pop(); ok || q.Length() != 0 {
+			t.Fatalf("Pop of an empty queue succeeded, Length = %d", q.Length())
+		}
+	}
+}
+
+func TestLockFreeQueueFIFO(t *testing.T) {
+	q := NewLockFreeQueue[string]()
+	if _, ok := q.Pop(); ok {
+		t.Fatal("Pop of an empty queue succeeded")
+	}
+	for _, s := range []string{"a", "b", "c"} {
+		q.Push(s)
+	}
+	if q.Length() != 3 {
+		t.Fatalf("Length = %d, want 3", q.Length())
+	}
+	for _, want := range []string{"a", "b", "c"} {
+		if v, ok := q.Pop(); !ok || v != want {
+			t.Fatalf("Pop = %q, %v, want %q", v, ok, want)
+		}
+	}
+}
+
+// TestMPMC checks that every pushed value is popped exactly once, and that
+// the values of each producer are popped in order.
+func TestMPMC(t *testing.T) {
+	const producers, consumers, perProducer = 4, 4, 5000
+	for name, newFIFO := range fifos() {
+		t.Run(name, func(t *testing.T) {
+			q := newFIFO()
+			var wg sync.WaitGroup
+			for p := 0; p < producers; p++ {
+				wg.Add(1)
+				go func(p int) {
+					defer wg.Done()
+					for i := 0; i < perProducer; i++ {
+						q.Push(p*perProducer + i)
+					}
+				}(p)
+			}
+			results := make(chan []int, consumers)
+			var remaining sync.WaitGroup
+			remaining.Add(producers * perProducer)
+			done := make(chan struct{})
+			go func() {
+				remaining.Wait()
+				close(done)
+			}()
+			for c := 0; c < consumers; c++ {
+				go func() {
+					var popped []int
+					for {
+						select {
+						case <-done:
+							results <- popped
+							return
+						default:
+						}
+						if v, ok := q.Pop(); ok {
+							popped = append(popped, v)
+							remaining.Done()
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			seen := make([]bool, producers*perProducer)
+			for c := 0; c < consumers; c++ {
+				last := make([]int, producers)
+				for i := range last {
+					last[i] = -1
+				}
+				for _, v := range <-results {
+					if seen[v] {
+						t.Fatalf("value %d popped twice", v)
+					}
+					seen[v] = true
+					if p := v / perProducer; v <= last[p] {
+						t.Fatalf("value %d popped after %d", v, last[p])
+					} else {
+						last[p] = v
+					}
+				}
+			}
+			if q.Length() != 0 {
+				t.Fatalf("Length = %d after popping everything", q.Length())
+			}
+		})
+	}
+}
+
+// BenchmarkFIFOParallel pushes and pops one value per iteration from all
+// GOMAXPROCS goroutines.
+func BenchmarkFIFOParallel(b *testing.B) {
+	for name, newFIFO := range fifos() {
+		b.Run(name, func(b *testing.B) {
+			q := newFIFO()
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					q.Push(1)
+					for {
+						if _, ok := q.Pop(); ok {
+							break
+						}
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/funbytes/modern-go/internal/rwmutex"
+)
+
+// PriorityItem is the handle of an element in a PriorityQueue, returned by
+// Push and accepted by Update and Remove.
+type PriorityItem[T any] struct {
+	value T
+	index int // position in the heap, -1 once removed
+	owner interface{}
+}
+
+// Value returns the value of the element.
+func (it *PriorityItem[T]) Value() T {
+	return it.value
+}
+
+// itemHeap implements heap.Interface over items ordered by less.
+type itemHeap[T any] struct {
+	items []*PriorityItem[T]
+	less  func(a, b T) bool
+}
+
+func (h *itemHeap[T]) Len() int { return len(h.items) }
+
+func (h *itemHeap[T]) Less(i, j int) bool { return h.less(h.items[i].value, h.items[j].value) }
+
+func (h *itemHeap[T]) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *itemHeap[T]) Push(x interface{}) {
+	it := x.(*PriorityItem[T])
+	it.index = len(h.items)
+	h.items = append(h.items, it)
+}
+
+func (h *itemHeap[T]) Pop() interface{} {
+	n := len(h.items) - 1
+	it := h.items[n]
+	h.items[n] = nil
+	h.items = h.items[:n]
+	it.index = -1
+	return it
+}
+
+// contains reports whether it is an element of h owned by owner.
+func (h *itemHeap[T]) contains(it *PriorityItem[T], owner interface{}) bool {
+	return it != nil && it.owner == owner && it.index >= 0
+}
+
+// PriorityQueue is a queue whose Pop returns the element ordered first by a
+// user comparator.
+type PriorityQueue[T any] struct {
+	lock *rwmutex.RWMutex
+	heap itemHeap[T]
+}
+
+// NewPriorityQueue creates and returns an empty PriorityQueue, less reports
+// whether a must be popped before b. The queue is concurrent-safe unless
+// safe is given as false.
+func NewPriorityQueue[T any](less func(a, b T) bool, safe ...bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{
+		lock: rwmutex.NewDefaultSafe(safe...),
+		heap: itemHeap[T]{less: less},
+	}
+}
+
+// Length returns the number of elements in the queue.
+func (q *PriorityQueue[T]) Length() int {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return q.heap.Len()
+}
+
+// Len returns the number of elements in the queue.
+func (q *PriorityQueue[T]) Len() int {
+	return q.Length()
+}
+
+// IsEmpty reports whether the queue has no elements.
+func (q *PriorityQueue[T]) IsEmpty() bool {
+	return q.Length() == 0
+}
+
+// Push adds v to the queue, and returns its handle.
+func (q *PriorityQueue[T]) Push(v T) *PriorityItem[T] {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	it := &PriorityItem[T]{value: v, owner: q}
+	heap.Push(&q.heap, it)
+	return it
+}
+
+// Peek returns the first element without removing it, and reports false if
+// the queue is empty.
+func (q *PriorityQueue[T]) Peek() (T, bool) {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	if q.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	return q.heap.items[0].value, true
+}
+
+// Pop removes and returns the first element, and reports false if the queue
+// is empty.
+func (q *PriorityQueue[T]) Pop() (T, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	return heap.Pop(&q.heap).(*PriorityItem[T]).value, true
+}
+
+// Update replaces the value of it and moves it to its new position. It
+// reports false if it is not in the queue.
+func (q *PriorityQueue[T]) Update(it *PriorityItem[T], v T) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if !q.heap.contains(it, q) {
+		return false
+	}
+	it.value = v
+	heap.Fix(&q.heap, it.index)
+	return true
+}
+
+// Remove removes it from the queue, and reports false if it is not in the
+// queue.
+func (q *PriorityQueue[T]) Remove(it *PriorityItem[T]) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if !q.heap.contains(it, q) {
+		return false
+	}
+	heap.Remove(&q.heap, it.index)
+	return true
+}
+
+// Clear removes all elements, their handles become invalid.
+func (q *PriorityQueue[T]) Clear() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for _, it := range q.heap.items {
+		it.index = -1
+	}
+	q.heap.items = nil
+}
+
+type delayed[T any] struct {
+	value    T
+	deadline time.Time
+}
+
+// DelayQueue is a queue whose elements can only be taken once their
+// deadline has passed, earliest deadline first. It is safe for concurrent
+// use.
+type DelayQueue[T any] struct {
+	lock sync.Mutex
+	heap itemHeap[delayed[T]]
+	// wake is closed when the earliest deadline changes, to wake Take.
+	wake chan struct{}
+	now  func() time.Time
+}
+
+// NewDelayQueue creates and returns an empty DelayQueue.
+func NewDelayQueue[T any]() *DelayQueue[T] {
+	return &DelayQueue[T]{
+		heap: itemHeap[delayed[T]]{less: func(a, b delayed[T]) bool {
+			return a.deadline.Before(b.deadline)
+		}},
+		now: time.Now,
+	}
+}
+
+// Length returns the number of elements in the queue, expired or not.
+func (q *DelayQueue[T]) Length() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.heap.Len()
+}
+
+// Len returns the number of elements in the queue, expired or not.
+func (q *DelayQueue[T]) Len() int {
+	return q.Length()
+}
+
+// IsEmpty reports whether the queue has no elements.
+func (q *DelayQueue[T]) IsEmpty() bool {
+	return q.Length() == 0
+}
+
+// Clear removes all elements.
+func (q *DelayQueue[T]) Clear() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.heap.items = nil
+}
+
+// Push adds v to the queue, to be taken once deadline has passed.
+func (q *DelayQueue[T]) Push(v T, deadline time.Time) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	it := &PriorityItem[delayed[T]]{value: delayed[T]{v, deadline}, owner: q}
+	heap.Push(&q.heap, it)
+	if it.index == 0 && q.wake != nil {
+		close(q.wake)
+		q.wake = nil
+	}
+}
+
+// PushAfter adds v to the queue, to be taken once d has elapsed.
+func (q *DelayQueue[T]) PushAfter(v T, d time.Duration) {
+	q.Push(v, q.now().Add(d))
+}
+
+// Poll removes and returns the element with the earliest deadline if that
+// deadline has passed, and reports false otherwise.
+func (q *DelayQueue[T]) Poll() (T, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	v, _, ok := q.poll()
+	return v, ok
+}
+
+// Take removes and returns the element with the earliest deadline, sleeping
+// until that deadline has passed. It returns the error of ctx if ctx is done
+// first.
+func (q *DelayQueue[T]) Take(ctx context.Context) (T, error) {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		q.lock.Lock()
+		v, wait, ok := q.poll()
+		if ok {
+			q.lock.Unlock()
+			return v, nil
+		}
+		if q.wake == nil {
+			q.wake = make(chan struct{})
+		}
+		wake := q.wake
+		q.lock.Unlock()
+
+		var expired <-chan time.Time
+		if wait > 0 {
+			if timer == nil {
+				timer = time.NewTimer(wait)
+			} else {
+				timer.Reset(wait)
+			}
+			expired = timer.C
+		}
+		select {
+		case <-wake:
+			if timer != nil && !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-expired:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// poll pops the earliest element if it is expired. Otherwise it returns the
+// time left until the earliest deadline, or 0 for an empty queue. The caller
+// must hold the lock.
+func (q *DelayQueue[T]) poll() (T, time.Duration, bool) {
+	var zero T
+	if q.heap.Len() == 0 {
+		return zero, 0, false
+	}
+	if wait := q.heap.items[0].value.deadline.Sub(q.now()); wait > 0 {
+		return zero, wait, false
+	}
+	return heap.Pop(&q.heap).(*PriorityItem[delayed[T]]).value.value, 0, true
+}
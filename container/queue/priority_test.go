@@ -0,0 +1,117 @@
+package queue
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	q := NewPriorityQueue[int](func(a, b int) bool { return a < b })
+	if _, ok := q.Pop(); ok {
+		t.Fatal("Pop of an empty queue succeeded")
+	}
+	r := rand.New(rand.NewSource(1))
+	var want []int
+	items := make(map[int]*PriorityItem[int])
+	for i := 0; i < 100; i++ {
+		v := r.Intn(1000)
+		items[i] = q.Push(v)
+		want = append(want, v)
+	}
+	// Raise every tenth element above all others, and remove every seventh.
+	for i := 0; i < 100; i += 10 {
+		if !q.Update(items[i], 1000+i) {
+			t.Fatalf("Update(%d) failed", i)
+		}
+		want[i] = 1000 + i
+	}
+	for i := 0; i < 100; i += 7 {
+		if !q.Remove(items[i]) {
+			t.Fatalf("Remove(%d) failed", i)
+		}
+		want[i] = -1
+	}
+	if q.Remove(items[0]) || q.Update(items[7], 1) {
+		t.Fatal("a removed handle is still accepted")
+	}
+	other := NewPriorityQueue[int](func(a, b int) bool { return a < b })
+	if other.Remove(items[1]) || other.Update(items[1], 1) {
+		t.Fatal("a handle of another queue is accepted")
+	}
+	sort.Ints(want)
+	for len(want) > 0 && want[0] == -1 {
+		want = want[1:]
+	}
+	if q.Length() != len(want) {
+		t.Fatalf("Length = %d, want %d", q.Length(), len(want))
+	}
+	if v, ok := q.Peek(); !ok || v != want[0] {
+		t.Fatalf("Peek = %d, %v, want %d", v, ok, want[0])
+	}
+	for _, w := range want {
+		if v, ok := q.Pop(); !ok || v != w {
+			t.Fatalf("Pop = %d, %v, want %d", v, ok, w)
+		}
+	}
+	it := q.Push(1)
+	q.Clear()
+	if q.Length() != 0 || q.Remove(it) {
+		t.Fatal("Clear left elements or valid handles")
+	}
+}
+
+func TestDelayQueue(t *testing.T) {
+	q := NewDelayQueue[string]()
+	now := time.Unix(1000, 0)
+	q.now = func() time.Time { return now }
+	q.Push("b", now.Add(2*time.Second))
+	q.Push("a", now.Add(time.Second))
+	q.PushAfter("c", 3*time.Second)
+	if _, ok := q.Poll(); ok {
+		t.Fatal("Poll returned an element before its deadline")
+	}
+	now = now.Add(2 * time.Second)
+	for _, want := range []string{"a", "b"} {
+		if v, ok := q.Poll(); !ok || v != want {
+			t.Fatalf("Poll = %q, %v, want %q", v, ok, want)
+		}
+	}
+	if _, ok := q.Poll(); ok || q.Length() != 1 {
+		t.Fatalf("Poll returned c early, Length = %d", q.Length())
+	}
+}
+
+func TestDelayQueueTake(t *testing.T) {
+	q := NewDelayQueue[int]()
+	start := time.Now()
+	q.PushAfter(2, 40*time.Millisecond)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// An earlier deadline pushed while Take sleeps must wake it.
+		time.Sleep(5 * time.Millisecond)
+		q.PushAfter(1, 10*time.Millisecond)
+	}()
+	for _, want := range []int{1, 2} {
+		v, err := q.Take(context.Background())
+		if err != nil || v != want {
+			t.Fatalf("Take = %d, %v, want %d", v, err, want)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("Take returned after %v, before the 40ms deadline", elapsed)
+	}
+	wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	q.PushAfter(3, time.Hour)
+	if _, err := q.Take(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Take = %v, want DeadlineExceeded", err)
+	}
+}
@@ -2,19 +2,21 @@ package queue
 
 import (
 	"container/list"
-	"sync"
+
+	"github.com/funbytes/modern-go/internal/rwmutex"
 )
 
 type Queue struct {
 	list *list.List
-	lock *sync.RWMutex
+	lock *rwmutex.RWMutex
 }
 
 // 新建一个队列
-func NewQueue() *Queue {
+// The queue is concurrent-safe unless safe is given as false.
+func NewQueue(safe ...bool) *Queue {
 	this := new(Queue)
 	this.list = list.New()
-	this.lock = new(sync.RWMutex)
+	this.lock = rwmutex.NewDefaultSafe(safe...)
 	return this
 }
 
@@ -26,6 +28,16 @@ func (q *Queue) Length() int {
 	return l
 }
 
+// Len returns the number of elements.
+func (q *Queue) Len() int {
+	return q.Length()
+}
+
+// IsEmpty reports whether the queue has no elements.
+func (q *Queue) IsEmpty() bool {
+	return q.Length() == 0
+}
+
 // 返回队列头
 func (q *Queue) Front() interface{} {
 	q.lock.RLock()
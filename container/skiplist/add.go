@@ -0,0 +1,83 @@
+package skiplist
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	// ErrIncompatibleFlags is returned by Add for a combination of flags
+	// that redis ZADD rejects as well.
+	ErrIncompatibleFlags = errors.New("skiplist: GT, LT, and/or NX options at the same time are not compatible")
+	// ErrNaN is returned when an operation would make a score NaN.
+	ErrNaN = errors.New("skiplist: resulting score is not a number (NaN)")
+)
+
+// AddFlag changes the behavior of Add, like the options of redis ZADD.
+type AddFlag int
+
+const (
+	// AddNX only adds new elements and never updates existing ones.
+	AddNX AddFlag = 1 << iota
+	// AddXX only updates existing elements and never adds new ones.
+	AddXX
+	// AddGT only updates existing elements if the new score is greater.
+	AddGT
+	// AddLT only updates existing elements if the new score is less.
+	AddLT
+	// AddCH makes Add report changed elements instead of only added ones.
+	AddCH
+)
+
+// Add adds key with score or updates the score of an existing key according
+// to flags, all under one lock. It returns true if key was added, or with
+// AddCH if key was added or its score changed.
+func (s *Set) Add(key string, score float64, flags AddFlag) (added bool, err error) {
+	nx, xx, gt, lt := flags&AddNX != 0, flags&AddXX != 0, flags&AddGT != 0, flags&AddLT != 0
+	if (nx && (xx || gt || lt)) || (gt && lt) {
+		return false, ErrIncompatibleFlags
+	}
+	if math.IsNaN(score) {
+		return false, ErrNaN
+	}
+	if err := s.lockWrite(); err != nil {
+		return false, err
+	}
+	defer s.unlock(&err)
+	cur, ok := s.dict[key]
+	if !ok {
+		if xx {
+			return false, nil
+		}
+		s.insert(key, score)
+		return true, nil
+	}
+	if nx || (gt && score <= cur) || (lt && score >= cur) || score == cur {
+		return false, nil
+	}
+	s.updateScore(key, cur, score)
+	return flags&AddCH != 0, nil
+}
+
+// IncrBy increments the score of key by delta and returns the new score,
+// like redis ZINCRBY. A missing key is added with delta as its score.
+func (s *Set) IncrBy(key string, delta float64) (_ float64, err error) {
+	if err := s.lockWrite(); err != nil {
+		return 0, err
+	}
+	defer s.unlock(&err)
+	cur, ok := s.dict[key]
+	if !ok {
+		if math.IsNaN(delta) {
+			return 0, ErrNaN
+		}
+		s.insert(key, delta)
+		return delta, nil
+	}
+	score := cur + delta
+	if math.IsNaN(score) {
+		return 0, ErrNaN
+	}
+	s.updateScore(key, cur, score)
+	return score, nil
+}
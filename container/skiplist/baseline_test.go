@@ -0,0 +1,88 @@
+package skiplist
+
+import (
+	"math/rand"
+	"time"
+)
+
+const fixedProbability = 0.25
+
+// fixedList is the insert path of the skip list before elements were sized
+// to their height: every element holds DefaultMaxLevel links, and levels
+// come from the global math/rand source reseeded on every insert. It is
+// kept as the baseline of BenchmarkFixedLevelInsert1M.
+type fixedList struct {
+	header *fixedElement
+	tail   *fixedElement
+	length int64
+	level  int
+}
+
+type fixedElement struct {
+	key      member
+	backward *fixedElement
+	level    [DefaultMaxLevel]struct {
+		forward *fixedElement
+		span    int64
+	}
+}
+
+func newFixedList() *fixedList {
+	return &fixedList{header: new(fixedElement), level: 1}
+}
+
+func (sl *fixedList) insert(key member) {
+	var update [DefaultMaxLevel]*fixedElement
+	var rank [DefaultMaxLevel]int64
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && compareMembers(x.level[i].forward.key, key) < 0 {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	level := fixedRandomLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = sl.header
+			update[i].level[i].span = sl.length
+		}
+		sl.level = level
+	}
+	x = &fixedElement{key: key}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = (rank[0] - rank[i]) + 1
+	}
+	for i := level; i < sl.level; i++ {
+		update[i].level[i].span++
+	}
+	if update[0] != sl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		sl.tail = x
+	}
+	sl.length++
+}
+
+func fixedRandomLevel() int {
+	rand.Seed(time.Now().UnixNano())
+	level := 1
+	for float64(rand.Int63()&0xFFFF) < fixedProbability*0xFFFF {
+		level++
+	}
+	if level < DefaultMaxLevel {
+		return level
+	}
+	return DefaultMaxLevel
+}
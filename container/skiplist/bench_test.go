@@ -0,0 +1,143 @@
+package skiplist
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+const benchMembers = 1000000
+
+var (
+	benchKeys     []string
+	benchKeysOnce sync.Once
+)
+
+// keys returns the members used by the benchmarks, built on first use so
+// that plain test runs do not pay for them.
+func keys() []string {
+	benchKeysOnce.Do(func() {
+		benchKeys = make([]string, benchMembers)
+		for i := range benchKeys {
+			benchKeys[i] = fmt.Sprintf("member:%07d", i)
+		}
+	})
+	return benchKeys
+}
+
+// BenchmarkSetInsert1M inserts 1M members into an empty Set per iteration,
+// and reports the heap retained per member.
+func BenchmarkSetInsert1M(b *testing.B) {
+	benchmarkInsert(b, func() (func(key string, score float64), interface{}) {
+		s := NewSet()
+		return func(key string, score float64) { s.Set(key, score) }, s
+	})
+}
+
+// BenchmarkFixedLevelInsert1M is BenchmarkSetInsert1M on the layout Set had
+// before elements were sized to their height, see fixedList, so that the
+// two can be compared with benchstat.
+func BenchmarkFixedLevelInsert1M(b *testing.B) {
+	benchmarkInsert(b, func() (func(key string, score float64), interface{}) {
+		dict := make(map[string]float64)
+		sl := newFixedList()
+		return func(key string, score float64) {
+			dict[key] = score
+			sl.insert(member{score, key})
+		}, [2]interface{}{dict, sl}
+	})
+}
+
+// benchmarkInsert inserts 1M members per iteration into the set returned by
+// newSet with its set function.
+func benchmarkInsert(b *testing.B, newSet func() (set func(key string, score float64), keep interface{})) {
+	benchKeys := keys()
+	var before, after runtime.MemStats
+	start := time.Now()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		set, s := newSet()
+		for j, key := range benchKeys {
+			set(key, float64((j*7919)%benchMembers))
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/benchMembers, "B/member")
+		runtime.KeepAlive(s)
+	}
+	b.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(b.N*benchMembers), "ns/insert")
+}
+
+func BenchmarkSetGetRank1M(b *testing.B) {
+	benchKeys := keys()
+	s := NewSet()
+	for j, key := range benchKeys {
+		s.Set(key, float64((j*7919)%benchMembers))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.GetRank(benchKeys[i%benchMembers])
+	}
+}
+
+func BenchmarkSetIncrBy1M(b *testing.B) {
+	benchKeys := keys()
+	s := NewSet()
+	for j, key := range benchKeys {
+		s.Set(key, float64((j*7919)%benchMembers))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.IncrBy(benchKeys[(i*31)%benchMembers], 1)
+	}
+}
+
+const parallelMembers = 100000
+
+// benchmarkParallel runs a mixed workload of 80% lookups and 20% score
+// updates over parallelMembers members from all GOMAXPROCS goroutines.
+func benchmarkParallel(b *testing.B, set func(key string, score float64), get func(key string)) {
+	benchKeys := keys()[:parallelMembers]
+	for j, key := range benchKeys {
+		set(key, float64(j))
+	}
+	var seq uint64
+	var mu sync.Mutex
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		mu.Lock()
+		seq++
+		x := seq * 0x9e3779b97f4a7c15
+		mu.Unlock()
+		for pb.Next() {
+			x = splitmix64(x)
+			key := benchKeys[x%parallelMembers]
+			if x>>32%5 == 0 {
+				set(key, float64(x>>40))
+			} else {
+				get(key)
+			}
+		}
+	})
+}
+
+func BenchmarkSetParallel(b *testing.B) {
+	s := NewSet()
+	benchmarkParallel(b, func(key string, score float64) { s.Set(key, score) },
+		func(key string) { s.GetRank(key) })
+}
+
+func BenchmarkConcurrentSetParallel(b *testing.B) {
+	s := NewConcurrentSet()
+	benchmarkParallel(b, s.Set, func(key string) { s.GetScore(key) })
+}
+
+func BenchmarkSetParallelScore(b *testing.B) {
+	s := NewSet()
+	benchmarkParallel(b, func(key string, score float64) { s.Set(key, score) },
+		func(key string) { s.GetScore(key) })
+}
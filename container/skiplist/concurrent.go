@@ -0,0 +1,671 @@
+package skiplist
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// cnode is a node of the lazy skip list behind ConcurrentSet. Its links are
+// read without locks, and written by the goroutine holding the lock of the
+// node before them.
+type cnode struct {
+	key         member
+	next        []unsafe.Pointer // *cnode per level
+	marked      int32            // set once the node is being removed
+	fullyLinked int32            // set once the node is linked on all levels
+	mu          sync.Mutex
+}
+
+func (n *cnode) loadNext(level int) *cnode {
+	return (*cnode)(atomic.LoadPointer(&n.next[level]))
+}
+
+func (n *cnode) storeNext(level int, x *cnode) {
+	atomic.StorePointer(&n.next[level], unsafe.Pointer(x))
+}
+
+func (n *cnode) isMarked() bool {
+	return atomic.LoadInt32(&n.marked) != 0
+}
+
+func (n *cnode) isFullyLinked() bool {
+	return atomic.LoadInt32(&n.fullyLinked) != 0
+}
+
+// ConcurrentSet is a sorted set with the core API of Set, built on a lazy
+// skip list (Herlihy, Lev, Luchangco and Shavit): lookups and scans take no
+// locks, and writers only lock the few nodes around the changed element,
+// so writers of different keys proceed in parallel.
+//
+// Scans are weakly consistent, like the iterators of Java's
+// ConcurrentSkipListMap: they reflect some of the writes made during the
+// scan, and a key whose score changes may briefly be seen at both scores.
+// Ranks are not maintained by the list, so rank queries cost O(n).
+//
+// Operations which need the whole set to stay unchanged while they run are
+// deliberately left out, as only the single lock of Set provides that:
+// lex ranges, RemoveRangeByScore/Rank/Lex, the blocking pops, snapshots,
+// the log and JSON, and Union, Intersect and Diff. Use Set for them.
+type ConcurrentSet struct {
+	// seed and length are accessed atomically, and first so that they are
+	// 64-bit aligned on 32-bit platforms.
+	seed      uint64 // advanced atomically to generate levels
+	length    int64
+	head      *cnode
+	maxLevel  int
+	threshold uint32
+	dict      sync.Map // key -> float64
+	// keyLocks serialize the writers of the same key.
+	keyLocks [64]sync.Mutex
+}
+
+// NewConcurrentSet creates and returns an empty ConcurrentSet, opts
+// configure its skip list.
+func NewConcurrentSet(opts ...Option) *ConcurrentSet {
+	sl := New[member, struct{}](compareMembers, opts...)
+	return &ConcurrentSet{
+		head:      &cnode{next: make([]unsafe.Pointer, sl.maxLevel)},
+		maxLevel:  sl.maxLevel,
+		threshold: sl.threshold,
+		seed:      sl.rng,
+	}
+}
+
+func (s *ConcurrentSet) randomLevel() int {
+	x := splitmix64(atomic.AddUint64(&s.seed, 0x9e3779b97f4a7c15))
+	level := 1
+	for level < s.maxLevel && uint32(x) < s.threshold {
+		level++
+		if x >>= 32; x == 0 {
+			x = splitmix64(atomic.AddUint64(&s.seed, 0x9e3779b97f4a7c15))
+		}
+	}
+	return level
+}
+
+func (s *ConcurrentSet) keyLock(key string) *sync.Mutex {
+	return &s.keyLocks[keyHash(key)%uint32(len(s.keyLocks))]
+}
+
+// keyHash returns the FNV-1a hash of key.
+func keyHash(key string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return hash
+}
+
+// find fills preds and succs with the nodes around m on every level, and
+// returns the highest level where the node of m was found, or -1.
+func (s *ConcurrentSet) find(m member, preds, succs []*cnode) int {
+	found := -1
+	pred := s.head
+	for level := s.maxLevel - 1; level >= 0; level-- {
+		curr := pred.loadNext(level)
+		for curr != nil && compareMembers(curr.key, m) < 0 {
+			pred = curr
+			curr = pred.loadNext(level)
+		}
+		if found == -1 && curr != nil && compareMembers(curr.key, m) == 0 {
+			found = level
+		}
+		preds[level] = pred
+		succs[level] = curr
+	}
+	return found
+}
+
+// lockPreds locks the distinct nodes of preds[:top], and returns the number
+// of levels locked, which is less than top if validate failed on a level.
+func lockPreds(preds []*cnode, top int, validate func(level int) bool) int {
+	var prev *cnode
+	for level := 0; level < top; level++ {
+		if preds[level] != prev {
+			preds[level].mu.Lock()
+			prev = preds[level]
+		}
+		if !validate(level) {
+			return level + 1
+		}
+	}
+	return top
+}
+
+// unlockPreds unlocks the nodes locked by lockPreds.
+func unlockPreds(preds []*cnode, locked int) {
+	var prev *cnode
+	for level := 0; level < locked; level++ {
+		if preds[level] != prev {
+			preds[level].mu.Unlock()
+			prev = preds[level]
+		}
+	}
+}
+
+// add links a node for m, and reports false if it already exists.
+func (s *ConcurrentSet) add(m member) bool {
+	top := s.randomLevel()
+	preds := make([]*cnode, s.maxLevel)
+	succs := make([]*cnode, s.maxLevel)
+	for {
+		if found := s.find(m, preds, succs); found != -1 {
+			x := succs[found]
+			if !x.isMarked() {
+				for !x.isFullyLinked() {
+					runtime.Gosched()
+				}
+				return false
+			}
+			// x is being removed, retry once it is unlinked.
+			continue
+		}
+		valid := true
+		locked := lockPreds(preds, top, func(level int) bool {
+			pred, succ := preds[level], succs[level]
+			valid = !pred.isMarked() && (succ == nil || !succ.isMarked()) && pred.loadNext(level) == succ
+			return valid
+		})
+		if !valid {
+			unlockPreds(preds, locked)
+			continue
+		}
+		x := &cnode{key: m, next: make([]unsafe.Pointer, top)}
+		for level := 0; level < top; level++ {
+			x.storeNext(level, succs[level])
+		}
+		for level := 0; level < top; level++ {
+			preds[level].storeNext(level, x)
+		}
+		atomic.StoreInt32(&x.fullyLinked, 1)
+		unlockPreds(preds, locked)
+		atomic.AddInt64(&s.length, 1)
+		return true
+	}
+}
+
+// remove unlinks the node of m, and reports false if it does not exist.
+func (s *ConcurrentSet) remove(m member) bool {
+	preds := make([]*cnode, s.maxLevel)
+	succs := make([]*cnode, s.maxLevel)
+	var victim *cnode
+	for {
+		found := s.find(m, preds, succs)
+		if victim == nil {
+			if found == -1 {
+				return false
+			}
+			x := succs[found]
+			if !x.isFullyLinked() || len(x.next)-1 != found || x.isMarked() {
+				return false
+			}
+			x.mu.Lock()
+			if x.isMarked() {
+				x.mu.Unlock()
+				return false
+			}
+			atomic.StoreInt32(&x.marked, 1)
+			victim = x
+		}
+		top := len(victim.next)
+		valid := true
+		locked := lockPreds(preds, top, func(level int) bool {
+			pred := preds[level]
+			valid = !pred.isMarked() && pred.loadNext(level) == victim
+			return valid
+		})
+		if !valid {
+			unlockPreds(preds, locked)
+			continue
+		}
+		for level := top - 1; level >= 0; level-- {
+			preds[level].storeNext(level, victim.loadNext(level))
+		}
+		victim.mu.Unlock()
+		unlockPreds(preds, locked)
+		atomic.AddInt64(&s.length, -1)
+		return true
+	}
+}
+
+// Set adds key with score, or updates the score of key if it exists.
+// A NaN score is ignored, use Add to get ErrNaN instead.
+func (s *ConcurrentSet) Set(key string, score float64) {
+	if math.IsNaN(score) {
+		return
+	}
+	mu := s.keyLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+	s.setWithoutLock(key, score)
+}
+
+// setWithoutLock sets the score of key, the caller must hold its key lock.
+func (s *ConcurrentSet) setWithoutLock(key string, score float64) {
+	cur, ok := s.dict.Load(key)
+	if ok && cur.(float64) == score {
+		return
+	}
+	// Link the new position before unlinking the old one, so that scans
+	// never miss the key.
+	s.add(member{score, key})
+	s.dict.Store(key, score)
+	if ok {
+		s.remove(member{cur.(float64), key})
+	}
+}
+
+// Add adds key with score or updates the score of an existing key according
+// to flags, under the lock of key, see Set.Add.
+func (s *ConcurrentSet) Add(key string, score float64, flags AddFlag) (bool, error) {
+	nx, xx, gt, lt := flags&AddNX != 0, flags&AddXX != 0, flags&AddGT != 0, flags&AddLT != 0
+	if (nx && (xx || gt || lt)) || (gt && lt) {
+		return false, ErrIncompatibleFlags
+	}
+	if math.IsNaN(score) {
+		return false, ErrNaN
+	}
+	mu := s.keyLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+	v, ok := s.dict.Load(key)
+	if !ok {
+		if xx {
+			return false, nil
+		}
+		s.setWithoutLock(key, score)
+		return true, nil
+	}
+	cur := v.(float64)
+	if nx || (gt && score <= cur) || (lt && score >= cur) || score == cur {
+		return false, nil
+	}
+	s.setWithoutLock(key, score)
+	return flags&AddCH != 0, nil
+}
+
+// IncrBy increments the score of key by delta and returns the new score,
+// like Set.IncrBy.
+func (s *ConcurrentSet) IncrBy(key string, delta float64) (float64, error) {
+	mu := s.keyLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+	score := delta
+	if cur, ok := s.dict.Load(key); ok {
+		score += cur.(float64)
+	}
+	if math.IsNaN(score) {
+		return 0, ErrNaN
+	}
+	s.setWithoutLock(key, score)
+	return score, nil
+}
+
+// Del removes key.
+func (s *ConcurrentSet) Del(key string) error {
+	mu := s.keyLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+	cur, ok := s.dict.Load(key)
+	if !ok {
+		return notFound(key)
+	}
+	s.dict.Delete(key)
+	s.remove(member{cur.(float64), key})
+	return nil
+}
+
+// GetScore returns the score of key.
+func (s *ConcurrentSet) GetScore(key string) (float64, error) {
+	if cur, ok := s.dict.Load(key); ok {
+		return cur.(float64), nil
+	}
+	return 0, notFound(key)
+}
+
+// HasKey reports whether key exists.
+func (s *ConcurrentSet) HasKey(key string) bool {
+	_, ok := s.dict.Load(key)
+	return ok
+}
+
+// GetLength returns the number of elements.
+func (s *ConcurrentSet) GetLength() int64 {
+	return atomic.LoadInt64(&s.length)
+}
+
+// GetLenth returns the number of elements.
+//
+// Deprecated: use GetLength.
+func (s *ConcurrentSet) GetLenth() int64 {
+	return s.GetLength()
+}
+
+// first returns the first live node not before m.
+func (s *ConcurrentSet) first(m member) *cnode {
+	pred := s.head
+	var curr *cnode
+	for level := s.maxLevel - 1; level >= 0; level-- {
+		curr = pred.loadNext(level)
+		for curr != nil && compareMembers(curr.key, m) < 0 {
+			pred = curr
+			curr = pred.loadNext(level)
+		}
+	}
+	return s.live(curr)
+}
+
+// live returns x or the first node after it which is linked and not being removed.
+func (s *ConcurrentSet) live(x *cnode) *cnode {
+	for x != nil && (x.isMarked() || !x.isFullyLinked()) {
+		x = x.loadNext(0)
+	}
+	return x
+}
+
+// GetRank returns the 1-based rank of key in ascending order, in O(n).
+func (s *ConcurrentSet) GetRank(key string) (int64, error) {
+	cur, ok := s.dict.Load(key)
+	if !ok {
+		return 0, notFound(key)
+	}
+	m := member{cur.(float64), key}
+	var rank int64
+	for x := s.live(s.head.loadNext(0)); x != nil && compareMembers(x.key, m) <= 0; x = s.live(x.loadNext(0)) {
+		rank++
+	}
+	return rank, nil
+}
+
+// GetRankDESC returns the 1-based rank of key in descending order, in O(n).
+func (s *ConcurrentSet) GetRankDESC(key string) (int64, error) {
+	cur, ok := s.dict.Load(key)
+	if !ok {
+		return 0, notFound(key)
+	}
+	m := member{cur.(float64), key}
+	var rank int64
+	for x := s.live(s.head.loadNext(0)); x != nil; x = s.live(x.loadNext(0)) {
+		if compareMembers(x.key, m) >= 0 {
+			rank++
+		}
+	}
+	return rank, nil
+}
+
+// ElementByRankASC returns the element of 1-based rank in ascending order,
+// and false if there is no such element, in O(rank).
+func (s *ConcurrentSet) ElementByRankASC(rank int64) (Node, bool) {
+	if rank < 1 {
+		return Node{}, false
+	}
+	var n Node
+	var found bool
+	s.IteratorAsc(func(node Node) bool {
+		if rank--; rank == 0 {
+			n, found = node, true
+		}
+		return !found
+	})
+	return n, found
+}
+
+// ElementByRankDESC returns the element of 1-based rank in descending order,
+// and false if there is no such element, in O(n).
+func (s *ConcurrentSet) ElementByRankDESC(rank int64) (Node, bool) {
+	if nodes := s.GetTopNDESC(rank); rank >= 1 && int64(len(nodes)) == rank {
+		return nodes[rank-1], true
+	}
+	return Node{}, false
+}
+
+// IteratorAsc iterates the elements in ascending order with given callback
+// function f. If f returns true, then it continues iterating; or false to stop.
+func (s *ConcurrentSet) IteratorAsc(f func(n Node) bool) {
+	for x := s.live(s.head.loadNext(0)); x != nil; x = s.live(x.loadNext(0)) {
+		if !f(x.node()) {
+			break
+		}
+	}
+}
+
+func (x *cnode) node() Node {
+	return Node{Key: x.key.key, Score: x.key.score}
+}
+
+// GetTopN returns the first n elements in ascending order.
+func (s *ConcurrentSet) GetTopN(n int64) []Node {
+	var nodes []Node
+	if n <= 0 {
+		return nil
+	}
+	s.IteratorAsc(func(node Node) bool {
+		nodes = append(nodes, node)
+		return int64(len(nodes)) < n
+	})
+	return nodes
+}
+
+// GetTopNDESC returns the last n elements in descending order, in O(n)
+// as the list has no backward links.
+func (s *ConcurrentSet) GetTopNDESC(n int64) []Node {
+	if l := s.GetLength(); n > l {
+		n = l
+	}
+	if n <= 0 {
+		return nil
+	}
+	// Keep the last n elements seen in a ring.
+	ring := make([]Node, 0, n)
+	next := 0
+	s.IteratorAsc(func(node Node) bool {
+		if int64(len(ring)) < n {
+			ring = append(ring, node)
+		} else {
+			ring[next] = node
+			next = (next + 1) % len(ring)
+		}
+		return true
+	})
+	nodes := make([]Node, len(ring))
+	for i := range nodes {
+		nodes[i] = ring[(next+len(ring)-1-i)%len(ring)]
+	}
+	return nodes
+}
+
+// RangeByScore returns the elements with a score in r, see Set.RangeByScore.
+// Scanning in descending order costs O(number of elements in r).
+func (s *ConcurrentSet) RangeByScore(r ScoreRange, offset, limit int64, desc bool) []Node {
+	if offset < 0 || limit == 0 || r.isEmpty() {
+		return nil
+	}
+	var nodes []Node
+	start := member{score: r.Min}
+	for x := s.first(start); x != nil && r.lteMax(x.key); x = s.live(x.loadNext(0)) {
+		if !r.gteMin(x.key) {
+			continue
+		}
+		if !desc && offset > 0 {
+			offset--
+			continue
+		}
+		nodes = append(nodes, x.node())
+		if !desc && limit > 0 && int64(len(nodes)) == limit {
+			break
+		}
+	}
+	if !desc {
+		return nodes
+	}
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	if offset >= int64(len(nodes)) {
+		return nil
+	}
+	nodes = nodes[offset:]
+	if limit > 0 && int64(len(nodes)) > limit {
+		nodes = nodes[:limit]
+	}
+	return nodes
+}
+
+// RangeByRank returns the elements ranked from start to stop, both inclusive,
+// see Set.RangeByRank. Ranks from the end and descending order are resolved
+// on a copy of all elements, so they cost O(n).
+func (s *ConcurrentSet) RangeByRank(start, stop int64, desc bool) []Node {
+	if !desc && start > 0 && stop > 0 {
+		if start > stop {
+			return nil
+		}
+		nodes := s.GetTopN(stop)
+		if int64(len(nodes)) < start {
+			return nil
+		}
+		return nodes[start-1:]
+	}
+	all := s.GetTopN(math.MaxInt64)
+	start, stop, ok := rankRange(start, stop, int64(len(all)))
+	if !ok {
+		return nil
+	}
+	if !desc {
+		return all[start-1 : stop]
+	}
+	nodes := make([]Node, 0, stop-start+1)
+	for i := int64(len(all)) - start; i >= int64(len(all))-stop; i-- {
+		nodes = append(nodes, all[i])
+	}
+	return nodes
+}
+
+// CountInScore returns the number of elements with a score in r, in
+// O(number of elements in r).
+func (s *ConcurrentSet) CountInScore(r ScoreRange) int64 {
+	if r.isEmpty() {
+		return 0
+	}
+	var count int64
+	for x := s.first(member{score: r.Min}); x != nil && r.lteMax(x.key); x = s.live(x.loadNext(0)) {
+		if r.gteMin(x.key) {
+			count++
+		}
+	}
+	return count
+}
+
+// PopMin removes and returns up to n elements with the lowest scores in
+// ascending order, see Set.PopMin. An element whose score changes while it
+// is being popped is left in place, and the next lowest one is taken instead.
+func (s *ConcurrentSet) PopMin(n int64) []Node {
+	return s.pop(n, s.GetTopN)
+}
+
+// PopMax removes and returns up to n elements with the highest scores in
+// descending order, like PopMin. It costs O(n) as the list has no backward links.
+func (s *ConcurrentSet) PopMax(n int64) []Node {
+	return s.pop(n, s.GetTopNDESC)
+}
+
+// pop removes the elements returned by candidates until n are removed or
+// none are left.
+func (s *ConcurrentSet) pop(n int64, candidates func(n int64) []Node) []Node {
+	var nodes []Node
+	for int64(len(nodes)) < n {
+		found := candidates(n - int64(len(nodes)))
+		if len(found) == 0 {
+			break
+		}
+		for _, c := range found {
+			if s.delIf(c) {
+				nodes = append(nodes, c)
+			}
+		}
+	}
+	return nodes
+}
+
+// delIf removes n.Key if its score is still n.Score.
+func (s *ConcurrentSet) delIf(n Node) bool {
+	mu := s.keyLock(n.Key)
+	mu.Lock()
+	defer mu.Unlock()
+	if cur, ok := s.dict.Load(n.Key); !ok || cur.(float64) != n.Score {
+		return false
+	}
+	s.dict.Delete(n.Key)
+	s.remove(member{n.Score, n.Key})
+	return true
+}
+
+// Scan returns a page of up to count elements following the cursor after,
+// see Set.Scan. Scanning in descending order costs O(number of elements
+// before the cursor).
+func (s *ConcurrentSet) Scan(after *Cursor, count int64, desc bool) ([]Node, *Cursor) {
+	return s.ScanByScore(ScoreRange{Min: math.Inf(-1), Max: math.Inf(1)}, after, count, desc)
+}
+
+// ScanByScore is like Scan, but only returns elements with a score in r.
+func (s *ConcurrentSet) ScanByScore(r ScoreRange, after *Cursor, count int64, desc bool) ([]Node, *Cursor) {
+	if count <= 0 || r.isEmpty() {
+		return nil, nil
+	}
+	start := member{score: r.Min}
+	if after != nil && !desc && compareMembers(after.member(), start) > 0 {
+		start = after.member()
+	}
+	var nodes []Node
+	for x := s.first(start); x != nil && r.lteMax(x.key); x = s.live(x.loadNext(0)) {
+		if !r.gteMin(x.key) {
+			continue
+		}
+		if after != nil {
+			if c := compareMembers(x.key, after.member()); desc && c >= 0 {
+				break
+			} else if !desc && c <= 0 {
+				continue
+			}
+		}
+		if !desc && int64(len(nodes)) == count {
+			last := nodes[len(nodes)-1]
+			return nodes, &Cursor{Score: last.Score, Key: last.Key}
+		}
+		nodes = append(nodes, x.node())
+	}
+	if !desc {
+		return nodes, nil
+	}
+	page := make([]Node, 0, count)
+	for i := len(nodes) - 1; i >= 0 && int64(len(page)) < count; i-- {
+		page = append(page, nodes[i])
+	}
+	if len(page) < len(nodes) {
+		last := page[len(page)-1]
+		return page, &Cursor{Score: last.Score, Key: last.Key}
+	}
+	return page, nil
+}
+
+// Len returns the number of elements.
+func (s *ConcurrentSet) Len() int {
+	return int(s.GetLength())
+}
+
+// IsEmpty reports whether the ConcurrentSet has no elements.
+func (s *ConcurrentSet) IsEmpty() bool {
+	return s.GetLength() == 0
+}
+
+// Clear removes all elements. Unlike Set.Clear it removes them one by one,
+// so elements added during the call may be kept.
+func (s *ConcurrentSet) Clear() {
+	s.dict.Range(func(key, _ any) bool {
+		s.Del(key.(string))
+		return true
+	})
+}
@@ -0,0 +1,274 @@
+package skiplist
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// dumpConcurrent returns all elements of s walking the bottom level.
+func dumpConcurrent(s *ConcurrentSet) []Node {
+	var nodes []Node
+	s.IteratorAsc(func(n Node) bool {
+		nodes = append(nodes, n)
+		return true
+	})
+	return nodes
+}
+
+func TestConcurrentSetModel(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	s, model := NewConcurrentSet(), NewSet()
+	for i := 0; i < 5000; i++ {
+		key := fmt.Sprintf("k%03d", r.Intn(300))
+		switch r.Intn(4) {
+		case 0:
+			if err, want := s.Del(key), model.Del(key); (err == nil) != (want == nil) {
+				t.Fatalf("Del(%s) = %v, want %v", key, err, want)
+			}
+		case 1:
+			delta := float64(r.Intn(10))
+			got, _ := s.IncrBy(key, delta)
+			want, _ := model.IncrBy(key, delta)
+			if got != want {
+				t.Fatalf("IncrBy(%s) = %v, want %v", key, got, want)
+			}
+		default:
+			score := float64(r.Intn(100))
+			s.Set(key, score)
+			model.Set(key, score)
+		}
+	}
+	nodes := dump(model)
+	if got := dumpConcurrent(s); !reflect.DeepEqual(got, nodes) {
+		t.Fatalf("elements = %v, want %v", got, nodes)
+	}
+	if s.GetLength() != model.GetLength() {
+		t.Fatalf("GetLength = %d, want %d", s.GetLength(), model.GetLength())
+	}
+	for i, n := range nodes {
+		if rank, err := s.GetRank(n.Key); err != nil || rank != int64(i+1) {
+			t.Fatalf("GetRank(%s) = %d, %v, want %d", n.Key, rank, err, i+1)
+		}
+		if score, err := s.GetScore(n.Key); err != nil || score != n.Score {
+			t.Fatalf("GetScore(%s) = %v, %v, want %v", n.Key, score, err, n.Score)
+		}
+	}
+	if _, err := s.GetScore("missing"); err == nil || s.HasKey("missing") {
+		t.Error("missing key found")
+	}
+	if got, want := s.GetTopN(10), model.GetTopN(10); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTopN = %v, want %v", got, want)
+	}
+	if got, want := s.GetTopNDESC(10), model.GetTopNDESC(10); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTopNDESC = %v, want %v", got, want)
+	}
+	for _, r := range []ScoreRange{{Min: 10, Max: 40}, {Min: 10, Max: 40, MinExclusive: true, MaxExclusive: true}, {Min: 50, Max: 20}} {
+		for _, desc := range []bool{false, true} {
+			got, want := s.RangeByScore(r, 3, 7, desc), model.RangeByScore(r, 3, 7, desc)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("RangeByScore(%+v, 3, 7, %v) = %v, want %v", r, desc, got, want)
+			}
+		}
+	}
+}
+
+func TestConcurrentSetParity(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	s, model := NewConcurrentSet(), NewSet()
+	flags := []AddFlag{0, AddNX, AddXX, AddGT, AddLT, AddCH, AddXX | AddGT | AddCH, AddNX | AddGT}
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("k%03d", r.Intn(200))
+		score, flag := float64(r.Intn(50)), flags[r.Intn(len(flags))]
+		got, err := s.Add(key, score, flag)
+		want, wantErr := model.Add(key, score, flag)
+		if got != want || err != wantErr {
+			t.Fatalf("Add(%s, %v, %d) = (%v, %v), want (%v, %v)", key, score, flag, got, err, want, wantErr)
+		}
+	}
+	if _, err := s.Add("nan", math.NaN(), 0); err != ErrNaN {
+		t.Errorf("Add(NaN) = %v, want ErrNaN", err)
+	}
+	s.Set("inf", math.Inf(1))
+	model.Set("inf", math.Inf(1))
+	if _, err := s.IncrBy("inf", math.Inf(-1)); err != ErrNaN {
+		t.Errorf("IncrBy(+Inf, -Inf) = %v, want ErrNaN", err)
+	}
+	if s.Set("nan", math.NaN()); s.HasKey("nan") {
+		t.Error("Set added a NaN score")
+	}
+	nodes := dump(model)
+	if got := dumpConcurrent(s); !reflect.DeepEqual(got, nodes) {
+		t.Fatalf("elements = %v, want %v", got, nodes)
+	}
+
+	for _, n := range nodes[:20] {
+		got, _ := s.GetRankDESC(n.Key)
+		if want, _ := model.GetRankDESC(n.Key); got != want {
+			t.Fatalf("GetRankDESC(%s) = %d, want %d", n.Key, got, want)
+		}
+	}
+	for _, rank := range []int64{-1, 0, 1, 7, int64(len(nodes)), int64(len(nodes)) + 1} {
+		for _, desc := range []bool{false, true} {
+			get, getModel := s.ElementByRankASC, model.ElementByRankASC
+			if desc {
+				get, getModel = s.ElementByRankDESC, model.ElementByRankDESC
+			}
+			n, ok := get(rank)
+			if want, wantOK := getModel(rank); n != want || ok != wantOK {
+				t.Errorf("ElementByRank(%d, desc %v) = (%v, %v), want (%v, %v)", rank, desc, n, ok, want, wantOK)
+			}
+		}
+	}
+	for _, rng := range [][2]int64{{1, -1}, {3, 9}, {-5, -2}, {9, 3}, {0, 2}, {-1000, 4}, {5, 1000}, {1000, 1001}} {
+		for _, desc := range []bool{false, true} {
+			got, want := s.RangeByRank(rng[0], rng[1], desc), model.RangeByRank(rng[0], rng[1], desc)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("RangeByRank(%d, %d, %v) = %v, want %v", rng[0], rng[1], desc, got, want)
+			}
+		}
+	}
+	for _, rng := range []ScoreRange{{Min: 10, Max: 40}, {Min: 10, Max: 40, MinExclusive: true, MaxExclusive: true}, {Min: 50, Max: 20}} {
+		if got, want := s.CountInScore(rng), model.CountInScore(rng); got != want {
+			t.Errorf("CountInScore(%+v) = %d, want %d", rng, got, want)
+		}
+		for _, desc := range []bool{false, true} {
+			var after, wantAfter *Cursor
+			for page := 0; ; page++ {
+				var got, want []Node
+				got, after = s.ScanByScore(rng, after, 7, desc)
+				want, wantAfter = model.ScanByScore(rng, wantAfter, 7, desc)
+				if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(after, wantAfter) {
+					t.Fatalf("ScanByScore(%+v, desc %v) page %d = %v, %v, want %v, %v", rng, desc, page, got, after, want, wantAfter)
+				}
+				if after == nil {
+					break
+				}
+			}
+		}
+	}
+
+	if got, want := s.PopMin(3), model.PopMin(3); !reflect.DeepEqual(got, want) {
+		t.Errorf("PopMin = %v, want %v", got, want)
+	}
+	if got, want := s.PopMax(3), model.PopMax(3); !reflect.DeepEqual(got, want) {
+		t.Errorf("PopMax = %v, want %v", got, want)
+	}
+	if s.Len() != model.Len() {
+		t.Errorf("Len = %d, want %d", s.Len(), model.Len())
+	}
+	if s.Clear(); !s.IsEmpty() || s.PopMin(1) != nil || s.PopMax(math.MaxInt64) != nil {
+		t.Error("Clear left elements")
+	}
+}
+
+func TestConcurrentSetPop(t *testing.T) {
+	const workers, keys = 8, 2000
+	s := NewConcurrentSet()
+	for i := 0; i < keys; i++ {
+		s.Set(fmt.Sprintf("k%04d", i), float64(i))
+	}
+	popped := make([][]Node, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for {
+				var nodes []Node
+				if w%2 == 0 {
+					nodes = s.PopMin(7)
+				} else {
+					nodes = s.PopMax(7)
+				}
+				if nodes == nil {
+					return
+				}
+				popped[w] = append(popped[w], nodes...)
+			}
+		}(w)
+	}
+	wg.Wait()
+	seen := make(map[string]bool)
+	for _, nodes := range popped {
+		for _, n := range nodes {
+			if seen[n.Key] {
+				t.Fatalf("%s popped twice", n.Key)
+			}
+			seen[n.Key] = true
+		}
+	}
+	if len(seen) != keys || !s.IsEmpty() {
+		t.Errorf("popped %d of %d elements, %d left", len(seen), keys, s.GetLength())
+	}
+}
+
+func TestConcurrentSetStress(t *testing.T) {
+	const workers, ops, keys = 8, 2000, 50
+	s := NewConcurrentSet()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(w)))
+			for i := 0; i < ops; i++ {
+				key := fmt.Sprintf("k%02d", r.Intn(keys))
+				switch r.Intn(5) {
+				case 0:
+					s.Del(key)
+				case 1:
+					s.IncrBy(key, 1)
+				case 2:
+					s.RangeByScore(ScoreRange{Min: 10, Max: 20}, 0, -1, r.Intn(2) == 0)
+				case 3:
+					s.GetRank(key)
+				default:
+					s.Set(key, float64(r.Intn(30)))
+				}
+			}
+		}(w)
+	}
+	// Scans run alongside the writers and must stay sorted.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			var prev *Node
+			s.IteratorAsc(func(n Node) bool {
+				if prev != nil && compareMembers(member{prev.Score, prev.Key}, member{n.Score, n.Key}) >= 0 {
+					t.Errorf("scan returned %v after %v", n, *prev)
+					return false
+				}
+				prev = &n
+				return true
+			})
+		}
+	}()
+	wg.Wait()
+	<-done
+
+	nodes := dumpConcurrent(s)
+	if int64(len(nodes)) != s.GetLength() {
+		t.Fatalf("walked %d elements, GetLength = %d", len(nodes), s.GetLength())
+	}
+	seen := make(map[string]bool)
+	for _, n := range nodes {
+		if seen[n.Key] {
+			t.Fatalf("key %s linked twice", n.Key)
+		}
+		seen[n.Key] = true
+		if score, err := s.GetScore(n.Key); err != nil || score != n.Score {
+			t.Fatalf("GetScore(%s) = %v, %v, linked with %v", n.Key, score, err, n.Score)
+		}
+	}
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("k%02d", i)
+		if s.HasKey(key) != seen[key] {
+			t.Fatalf("HasKey(%s) = %v, linked = %v", key, s.HasKey(key), seen[key])
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package skiplist
+
+import "errors"
+
+// LexRange is an interval of keys, like the min and max arguments of redis
+// ZRANGEBYLEX. Keys are compared bytewise, and the ranges are only meaningful
+// when all elements of the set have the same score, since elements are
+// ordered by score first.
+type LexRange struct {
+	Min          string
+	Max          string
+	MinExclusive bool
+	MaxExclusive bool
+	// MinUnbounded ignores Min, like "-" in redis.
+	MinUnbounded bool
+	// MaxUnbounded ignores Max, like "+" in redis.
+	MaxUnbounded bool
+}
+
+// ParseLexRange parses min and max in the syntax of redis ZRANGEBYLEX,
+// where "[a" includes a, "(a" excludes a, "-" is the lowest and "+" is the
+// highest possible key.
+func ParseLexRange(min, max string) (LexRange, error) {
+	var r LexRange
+	var err error
+	if r.Min, r.MinExclusive, r.MinUnbounded, err = parseLexItem(min, "-"); err != nil {
+		return r, err
+	}
+	if r.Max, r.MaxExclusive, r.MaxUnbounded, err = parseLexItem(max, "+"); err != nil {
+		return r, err
+	}
+	if min == "+" || max == "-" {
+		// Nothing is above "+" or below "-".
+		r = LexRange{Min: "b", Max: "a"}
+	}
+	return r, nil
+}
+
+func parseLexItem(item, unbounded string) (string, bool, bool, error) {
+	switch {
+	case item == unbounded:
+		return "", false, true, nil
+	case item == "+" || item == "-":
+		return "", false, false, nil
+	case len(item) > 0 && item[0] == '[':
+		return item[1:], false, false, nil
+	case len(item) > 0 && item[0] == '(':
+		return item[1:], true, false, nil
+	}
+	return "", false, false, errors.New("skiplist: min or max not valid string range item")
+}
+
+// PrefixRange returns the range of all keys starting with prefix.
+func PrefixRange(prefix string) LexRange {
+	r := LexRange{Min: prefix, MaxUnbounded: true}
+	// The keys with the prefix end before the prefix with its last
+	// non-0xff byte incremented.
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			r.Max = prefix[:i] + string([]byte{prefix[i] + 1})
+			r.MaxExclusive, r.MaxUnbounded = true, false
+			break
+		}
+	}
+	return r
+}
+
+func (r LexRange) isEmpty() bool {
+	if r.MinUnbounded || r.MaxUnbounded {
+		return false
+	}
+	return r.Min > r.Max || (r.Min == r.Max && (r.MinExclusive || r.MaxExclusive))
+}
+
+func (r LexRange) gteMin(m member) bool {
+	if r.MinUnbounded {
+		return true
+	}
+	if r.MinExclusive {
+		return m.key > r.Min
+	}
+	return m.key >= r.Min
+}
+
+func (r LexRange) lteMax(m member) bool {
+	if r.MaxUnbounded {
+		return true
+	}
+	if r.MaxExclusive {
+		return m.key < r.Max
+	}
+	return m.key <= r.Max
+}
+
+// RangeByLex returns the elements with a key in r, like redis ZRANGEBYLEX
+// with LIMIT offset count. It skips offset elements and returns at most
+// limit elements, a negative limit means no limit.
+// If desc is true, the elements are returned from the highest key down.
+func (s *Set) RangeByLex(r LexRange, offset, limit int64, desc bool) []Node {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.rangeBy(r, offset, limit, desc)
+}
+
+// CountByLex returns the number of elements with a key in r, like redis ZLEXCOUNT.
+func (s *Set) CountByLex(r LexRange) int64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.count(r)
+}
+
+// RemoveRangeByLex removes the elements with a key in r, like redis
+// ZREMRANGEBYLEX. It returns the number of removed elements.
+func (s *Set) RemoveRangeByLex(r LexRange) int64 {
+	if s.lockWrite() != nil {
+		return 0
+	}
+	defer s.unlock(nil)
+	return s.removeRange(r)
+}
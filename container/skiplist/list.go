@@ -0,0 +1,548 @@
+package skiplist
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Ordered is a constraint that permits any type supporting the operators < <= >= >.
+type Ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64 | ~string
+}
+
+// Compare returns -1 if a is less than b, +1 if a is greater than b, and 0 otherwise.
+// A float NaN is less than any other number and equal to NaN, so that NaN
+// keys have a place in the order.
+func Compare[K Ordered](a, b K) int {
+	aNaN, bNaN := a != a, b != b
+	if aNaN || bNaN {
+		switch {
+		case aNaN && bNaN:
+			return 0
+		case aNaN:
+			return -1
+		}
+		return 1
+	}
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// Element is an entry of a SkipList.
+type Element[K, V any] struct {
+	key      K
+	Value    V
+	backward *Element[K, V]
+	// level has one entry per level of the element. Most elements have
+	// a single level, which is stored inline to save an allocation.
+	level  []skipLevel[K, V]
+	inline [1]skipLevel[K, V]
+}
+
+// skipLevel is the link of an element on one level, span is the number of
+// elements between it and forward, counting forward.
+type skipLevel[K, V any] struct {
+	forward *Element[K, V]
+	span    int64
+}
+
+func newElement[K, V any](key K, value V, level int) *Element[K, V] {
+	x := &Element[K, V]{key: key, Value: value}
+	if level == 1 {
+		x.level = x.inline[:]
+	} else {
+		x.level = make([]skipLevel[K, V], level)
+	}
+	return x
+}
+
+// Key returns the key of e.
+func (e *Element[K, V]) Key() K {
+	return e.key
+}
+
+// Next returns the next element or nil.
+func (e *Element[K, V]) Next() *Element[K, V] {
+	return e.level[0].forward
+}
+
+// Prev returns the previous element or nil.
+func (e *Element[K, V]) Prev() *Element[K, V] {
+	return e.backward
+}
+
+// SkipList is an ordered map sorted by a user comparator, with the rank of
+// every element available in O(log n).
+// It is not safe for concurrent use, see Set for a locked sorted set on top of it.
+type SkipList[K, V any] struct {
+	header   *Element[K, V]
+	tail     *Element[K, V]
+	length   int64
+	level    int
+	maxLevel int
+	// threshold is the probability to promote an element to the next
+	// level, scaled to 32 bits.
+	threshold uint32
+	rng       uint64 // xorshift64* state
+	compare   func(a, b K) int
+}
+
+// Option configures a SkipList or a Set.
+type Option func(*options)
+
+type options struct {
+	maxLevel    int
+	probability float64
+	seed        int64
+	seeded      bool
+	unsafe      bool
+}
+
+// WithMaxLevel sets the maximum number of levels, between 1 and
+// DefaultMaxLevel, which is the default. Other values are ignored.
+func WithMaxLevel(level int) Option {
+	return func(o *options) {
+		if level >= 1 && level <= DefaultMaxLevel {
+			o.maxLevel = level
+		}
+	}
+}
+
+// WithProbability sets the probability for an element to reach the next
+// level, between 0 and 1 exclusive, 0.25 by default. Other values are ignored.
+func WithProbability(probability float64) Option {
+	return func(o *options) {
+		if probability > 0 && probability < 1 {
+			o.probability = probability
+		}
+	}
+}
+
+// WithSeed seeds the generator of element levels, which makes the shape of
+// the list reproducible. By default the generator is seeded from the clock.
+func WithSeed(seed int64) Option {
+	return func(o *options) {
+		o.seed, o.seeded = seed, true
+	}
+}
+
+// WithSafe sets whether a Set is concurrent-safe, which it is by default.
+// An unsafe Set takes no locks, for use by one goroutine at a time. It has
+// no effect on a SkipList, which is never concurrent-safe.
+func WithSafe(safe bool) Option {
+	return func(o *options) {
+		o.unsafe = !safe
+	}
+}
+
+var seedSequence uint64
+
+// New creates and returns an empty SkipList ordered by compare, which returns
+// a negative number if a < b, a positive number if a > b and 0 if they are equal.
+// compare must be a total order: a key which is not equal to itself, such as
+// a float NaN compared with < and >, cannot be found once put.
+func New[K, V any](compare func(a, b K) int, opts ...Option) *SkipList[K, V] {
+	o := options{maxLevel: DefaultMaxLevel, probability: p}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	seed := uint64(o.seed)
+	if !o.seeded {
+		seed = uint64(time.Now().UnixNano()) + atomic.AddUint64(&seedSequence, 1)
+	}
+	sl := &SkipList[K, V]{
+		level:     1,
+		maxLevel:  o.maxLevel,
+		threshold: uint32(o.probability * (1 << 32)),
+		rng:       splitmix64(seed),
+		compare:   compare,
+	}
+	if sl.rng == 0 {
+		sl.rng = 1
+	}
+	sl.header = newElement[K, V](*new(K), *new(V), sl.maxLevel)
+	return sl
+}
+
+// NewOrdered creates and returns an empty SkipList ordered by the natural order of K.
+func NewOrdered[K Ordered, V any](opts ...Option) *SkipList[K, V] {
+	return New[K, V](Compare[K], opts...)
+}
+
+// Len returns the number of elements.
+func (sl *SkipList[K, V]) Len() int64 {
+	return sl.length
+}
+
+// Level returns the current number of levels.
+func (sl *SkipList[K, V]) Level() int {
+	return sl.level
+}
+
+// Front returns the first element or nil.
+func (sl *SkipList[K, V]) Front() *Element[K, V] {
+	return sl.header.level[0].forward
+}
+
+// Back returns the last element or nil.
+func (sl *SkipList[K, V]) Back() *Element[K, V] {
+	return sl.tail
+}
+
+// Clear removes all elements.
+func (sl *SkipList[K, V]) Clear() {
+	sl.header = newElement[K, V](*new(K), *new(V), sl.maxLevel)
+	sl.tail = nil
+	sl.length = 0
+	sl.level = 1
+}
+
+// Get returns the value of key.
+func (sl *SkipList[K, V]) Get(key K) (value V, found bool) {
+	if x := sl.GetElement(key); x != nil {
+		return x.Value, true
+	}
+	return value, false
+}
+
+// GetElement returns the element of key or nil.
+func (sl *SkipList[K, V]) GetElement(key K) *Element[K, V] {
+	x, _ := sl.seekFirst(func(k K) bool { return sl.compare(k, key) < 0 })
+	if x != nil && sl.compare(x.key, key) == 0 {
+		return x
+	}
+	return nil
+}
+
+// Put sets the value of key, adding key if it does not exist. It returns nil
+// and leaves the list unchanged if key is not equal to itself by compare.
+func (sl *SkipList[K, V]) Put(key K, value V) *Element[K, V] {
+	if sl.compare(key, key) != 0 {
+		return nil
+	}
+	var update [DefaultMaxLevel]*Element[K, V]
+	var rank [DefaultMaxLevel]int64
+	if x := sl.findUpdate(key, &update, &rank); x != nil && sl.compare(x.key, key) == 0 {
+		x.Value = value
+		return x
+	}
+	return sl.insert(key, value, &update, &rank)
+}
+
+// Delete removes key and returns its value.
+func (sl *SkipList[K, V]) Delete(key K) (value V, found bool) {
+	var update [DefaultMaxLevel]*Element[K, V]
+	x := sl.findUpdate(key, &update, nil)
+	if x == nil || sl.compare(x.key, key) != 0 {
+		return value, false
+	}
+	sl.deleteElement(x, &update)
+	return x.Value, true
+}
+
+// Floor returns the element with the greatest key less than or equal to key, or nil.
+func (sl *SkipList[K, V]) Floor(key K) *Element[K, V] {
+	x, _ := sl.seekLast(func(k K) bool { return sl.compare(k, key) <= 0 })
+	return x
+}
+
+// Ceiling returns the element with the least key greater than or equal to key, or nil.
+func (sl *SkipList[K, V]) Ceiling(key K) *Element[K, V] {
+	x, _ := sl.seekFirst(func(k K) bool { return sl.compare(k, key) < 0 })
+	return x
+}
+
+// Lower returns the element with the greatest key strictly less than key, or nil.
+func (sl *SkipList[K, V]) Lower(key K) *Element[K, V] {
+	x, _ := sl.seekLast(func(k K) bool { return sl.compare(k, key) < 0 })
+	return x
+}
+
+// Higher returns the element with the least key strictly greater than key, or nil.
+func (sl *SkipList[K, V]) Higher(key K) *Element[K, V] {
+	x, _ := sl.seekFirst(func(k K) bool { return sl.compare(k, key) <= 0 })
+	return x
+}
+
+// Rank returns the 1-based rank of key in ascending order, or 0 if key does not exist.
+func (sl *SkipList[K, V]) Rank(key K) int64 {
+	x, rank := sl.seekLast(func(k K) bool { return sl.compare(k, key) <= 0 })
+	if x != nil && sl.compare(x.key, key) == 0 {
+		return rank
+	}
+	return 0
+}
+
+// GetByRank returns the element at the 1-based rank in ascending order,
+// or nil if rank is out of range.
+func (sl *SkipList[K, V]) GetByRank(rank int64) *Element[K, V] {
+	if rank < 1 || rank > sl.length {
+		return nil
+	}
+	x := sl.header
+	var traversed int64
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+// IteratorAsc iterates the list in ascending order with given callback function f.
+// If f returns true, then it continues iterating; or false to stop.
+func (sl *SkipList[K, V]) IteratorAsc(f func(key K, value V) bool) {
+	for x := sl.header.level[0].forward; x != nil; x = x.level[0].forward {
+		if !f(x.key, x.Value) {
+			break
+		}
+	}
+}
+
+// IteratorDesc iterates the list in descending order with given callback function f.
+// If f returns true, then it continues iterating; or false to stop.
+func (sl *SkipList[K, V]) IteratorDesc(f func(key K, value V) bool) {
+	for x := sl.tail; x != nil; x = x.backward {
+		if !f(x.key, x.Value) {
+			break
+		}
+	}
+}
+
+// seekFirst returns the first element for which before returns false, and its
+// rank, or nil if there is none. before must hold for a prefix of the list.
+func (sl *SkipList[K, V]) seekFirst(before func(k K) bool) (*Element[K, V], int64) {
+	x := sl.header
+	var rank int64
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && before(x.level[i].forward.key) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+	}
+	if x = x.level[0].forward; x == nil {
+		return nil, 0
+	}
+	return x, rank + 1
+}
+
+// seekLast returns the last element for which within returns true, and its
+// rank, or nil if there is none. within must hold for a prefix of the list.
+func (sl *SkipList[K, V]) seekLast(within func(k K) bool) (*Element[K, V], int64) {
+	x := sl.header
+	var rank int64
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && within(x.level[i].forward.key) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+	}
+	if x == sl.header {
+		return nil, 0
+	}
+	return x, rank
+}
+
+// findUpdate fills update with the rightmost element before key on every
+// level, and rank with their ranks if it is not nil. It returns the first
+// element not before key.
+func (sl *SkipList[K, V]) findUpdate(key K, update *[DefaultMaxLevel]*Element[K, V], rank *[DefaultMaxLevel]int64) *Element[K, V] {
+	x := sl.header
+	var traversed int64
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && sl.compare(x.level[i].forward.key, key) < 0 {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+		if rank != nil {
+			rank[i] = traversed
+		}
+	}
+	return x.level[0].forward
+}
+
+// insert adds a new element after update, as filled by findUpdate.
+func (sl *SkipList[K, V]) insert(key K, value V, update *[DefaultMaxLevel]*Element[K, V], rank *[DefaultMaxLevel]int64) *Element[K, V] {
+	level := sl.randomLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = sl.header
+			update[i].level[i].span = sl.length
+		}
+		sl.level = level
+	}
+	x := newElement(key, value, level)
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = (rank[0] - rank[i]) + 1
+	}
+	for i := level; i < sl.level; i++ {
+		update[i].level[i].span++
+	}
+	if update[0] != sl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		sl.tail = x
+	}
+	sl.length++
+	return x
+}
+
+// deleteElement unlinks x from the list, update holds the rightmost element
+// before x on every level.
+func (sl *SkipList[K, V]) deleteElement(x *Element[K, V], update *[DefaultMaxLevel]*Element[K, V]) {
+	for i := 0; i < sl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		sl.tail = x.backward
+	}
+	for sl.level > 1 && sl.header.level[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+}
+
+// updateKey changes the key of the element with key old to key, and returns
+// the element. The element is updated in place when its position in the list
+// would not change. old must exist.
+func (sl *SkipList[K, V]) updateKey(old, key K) *Element[K, V] {
+	var update [DefaultMaxLevel]*Element[K, V]
+	x := sl.findUpdate(old, &update, nil)
+	if (x.backward == nil || sl.compare(x.backward.key, key) < 0) &&
+		(x.level[0].forward == nil || sl.compare(x.level[0].forward.key, key) > 0) {
+		x.key = key
+		return x
+	}
+	sl.deleteElement(x, &update)
+	var rank [DefaultMaxLevel]int64
+	sl.findUpdate(key, &update, &rank)
+	return sl.insert(key, x.Value, &update, &rank)
+}
+
+// removeRange removes the elements from the first one for which before
+// returns false as long as while returns true, calling f for each of them.
+// It returns the number of removed elements.
+func (sl *SkipList[K, V]) removeRange(before, while func(k K) bool, f func(x *Element[K, V])) int64 {
+	var update [DefaultMaxLevel]*Element[K, V]
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && before(x.level[i].forward.key) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	x = x.level[0].forward
+	var removed int64
+	for x != nil && while(x.key) {
+		next := x.level[0].forward
+		sl.deleteElement(x, &update)
+		f(x)
+		removed++
+		x = next
+	}
+	return removed
+}
+
+// removeRangeByRank removes the elements ranked from start to stop, both
+// inclusive and 1-based, calling f for each of them.
+// It returns the number of removed elements.
+func (sl *SkipList[K, V]) removeRangeByRank(start, stop int64, f func(x *Element[K, V])) int64 {
+	var update [DefaultMaxLevel]*Element[K, V]
+	var traversed int64
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span < start {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	x = x.level[0].forward
+	var removed int64
+	for x != nil && start+removed <= stop {
+		next := x.level[0].forward
+		sl.deleteElement(x, &update)
+		f(x)
+		removed++
+		x = next
+	}
+	return removed
+}
+
+// rankRange clamps the 1-based ranks start and stop to the list, where
+// negative ranks count from the end (-1 is the last element).
+// It returns false if the range is empty.
+func (sl *SkipList[K, V]) rankRange(start, stop int64) (int64, int64, bool) {
+	return rankRange(start, stop, sl.length)
+}
+
+// rankRange is SkipList.rankRange for a list of length elements.
+func rankRange(start, stop, length int64) (int64, int64, bool) {
+	if start < 0 {
+		start += length + 1
+	}
+	if stop < 0 {
+		stop += length + 1
+	}
+	if start < 1 {
+		start = 1
+	}
+	if stop > length {
+		stop = length
+	}
+	return start, stop, start <= stop
+}
+
+// randomLevel returns the level of a new element, which reaches every next
+// level with the configured probability.
+func (sl *SkipList[K, V]) randomLevel() int {
+	level := 1
+	for level < sl.maxLevel && uint32(sl.random()>>32) < sl.threshold {
+		level++
+	}
+	return level
+}
+
+// random returns the next number of the xorshift64* generator of sl.
+func (sl *SkipList[K, V]) random() uint64 {
+	x := sl.rng
+	x ^= x >> 12
+	x ^= x << 25
+	x ^= x >> 27
+	sl.rng = x
+	return x * 2685821657736338717
+}
+
+// splitmix64 scrambles a seed, so that close seeds start far apart.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
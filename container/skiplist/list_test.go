@@ -0,0 +1,179 @@
+package skiplist
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSkipListModel(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	sl := NewOrdered[int, string]()
+	model := make(map[int]string)
+	for i := 0; i < 5000; i++ {
+		key := r.Intn(300)
+		if r.Intn(3) == 0 {
+			v, ok := sl.Delete(key)
+			mv, mok := model[key]
+			if ok != mok || v != mv {
+				t.Fatalf("Delete(%d) = (%q, %v), want (%q, %v)", key, v, ok, mv, mok)
+			}
+			delete(model, key)
+		} else {
+			value := string(rune('a' + r.Intn(26)))
+			sl.Put(key, value)
+			model[key] = value
+		}
+	}
+	keys := make([]int, 0, len(model))
+	for k := range model {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	if sl.Len() != int64(len(keys)) {
+		t.Fatalf("Len = %d, want %d", sl.Len(), len(keys))
+	}
+	i := 0
+	for x := sl.Front(); x != nil; x = x.Next() {
+		if x.Key() != keys[i] || x.Value != model[keys[i]] {
+			t.Fatalf("element %d = (%d, %q), want (%d, %q)", i, x.Key(), x.Value, keys[i], model[keys[i]])
+		}
+		if rank := sl.Rank(x.Key()); rank != int64(i+1) {
+			t.Fatalf("Rank(%d) = %d, want %d", x.Key(), rank, i+1)
+		}
+		if e := sl.GetByRank(int64(i + 1)); e != x {
+			t.Fatalf("GetByRank(%d) = %v, want key %d", i+1, e, x.Key())
+		}
+		i++
+	}
+	i = len(keys) - 1
+	sl.IteratorDesc(func(key int, value string) bool {
+		if key != keys[i] {
+			t.Fatalf("IteratorDesc key = %d, want %d", key, keys[i])
+		}
+		i--
+		return true
+	})
+	for key := -1; key <= 301; key++ {
+		j := sort.SearchInts(keys, key)
+		exact := j < len(keys) && keys[j] == key
+		check := func(name string, e *Element[int, string], want int) {
+			if want < 0 || want >= len(keys) {
+				if e != nil {
+					t.Fatalf("%s(%d) = %d, want nil", name, key, e.Key())
+				}
+				return
+			}
+			if e == nil || e.Key() != keys[want] {
+				t.Fatalf("%s(%d) = %v, want %d", name, key, e, keys[want])
+			}
+		}
+		floor, higher := j-1, j
+		if exact {
+			floor, higher = j, j+1
+		}
+		check("Floor", sl.Floor(key), floor)
+		check("Ceiling", sl.Ceiling(key), j)
+		check("Lower", sl.Lower(key), j-1)
+		check("Higher", sl.Higher(key), higher)
+		if _, ok := sl.Get(key); ok != exact {
+			t.Fatalf("Get(%d) found = %v, want %v", key, ok, exact)
+		}
+		if !exact && sl.Rank(key) != 0 {
+			t.Fatalf("Rank(%d) of a missing key = %d", key, sl.Rank(key))
+		}
+	}
+}
+
+func TestSkipListComparator(t *testing.T) {
+	sl := New[string, int](func(a, b string) int { return Compare(len(b), len(a)) })
+	for _, s := range []string{"a", "ccc", "bb", "dddd"} {
+		sl.Put(s, len(s))
+	}
+	sl.Put("xx", 20)
+	var keys []string
+	sl.IteratorAsc(func(key string, value int) bool {
+		keys = append(keys, key)
+		return len(keys) < 3
+	})
+	if len(keys) != 3 || keys[0] != "dddd" || keys[1] != "ccc" || keys[2] != "bb" {
+		t.Errorf("IteratorAsc = %q, want [dddd ccc bb]", keys)
+	}
+	if v, _ := sl.Get("zz"); v != 20 {
+		t.Errorf("Get(zz) = %d, want 20 as keys compare equal by length", v)
+	}
+	if e := sl.Back(); e.Key() != "a" || e.Prev().Key() != "bb" {
+		t.Errorf("Back = %q, want a after bb", e.Key())
+	}
+}
+
+func TestSkipListOptions(t *testing.T) {
+	sl := NewOrdered[int, struct{}](WithMaxLevel(4), WithProbability(0.5))
+	for i := 0; i < 10000; i++ {
+		sl.Put(i, struct{}{})
+	}
+	if sl.Level() > 4 {
+		t.Errorf("Level = %d with WithMaxLevel(4)", sl.Level())
+	}
+	for i := 1; i <= 10000; i += 997 {
+		if e := sl.GetByRank(int64(i)); e == nil || e.Key() != i-1 {
+			t.Fatalf("GetByRank(%d) = %v", i, e)
+		}
+	}
+	levels := func() []int {
+		sl := NewOrdered[int, struct{}](WithSeed(99), WithMaxLevel(100), WithProbability(2))
+		var levels []int
+		for i := 0; i < 100; i++ {
+			levels = append(levels, len(sl.Put(i, struct{}{}).level))
+		}
+		return levels
+	}
+	first, second := levels(), levels()
+	counts := make(map[int]int)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatal("lists with the same seed have different levels")
+		}
+		counts[first[i]]++
+	}
+	if counts[1] < 60 || counts[1] > 90 {
+		t.Errorf("%d of 100 elements have level 1 with the default probability 0.25", counts[1])
+	}
+}
+
+func TestSkipListNaN(t *testing.T) {
+	nan := math.NaN()
+	sl := NewOrdered[float64, int]()
+	for i, k := range []float64{2, nan, 1, math.Inf(-1)} {
+		sl.Put(k, i)
+	}
+	sl.Put(nan, 10)
+	if sl.Len() != 4 || !math.IsNaN(sl.Front().Key()) {
+		t.Fatalf("Len = %d, Front = %v, want 4 keys with NaN first", sl.Len(), sl.Front().Key())
+	}
+	if v, ok := sl.Get(nan); !ok || v != 10 {
+		t.Errorf("Get(NaN) = (%d, %v), want (10, true)", v, ok)
+	}
+	if rank := sl.Rank(nan); rank != 1 {
+		t.Errorf("Rank(NaN) = %d, want 1", rank)
+	}
+	if _, ok := sl.Delete(nan); !ok || sl.Len() != 3 {
+		t.Errorf("Delete(NaN) failed")
+	}
+
+	// Put rejects a key which is not equal to itself.
+	bad := New[float64, int](func(a, b float64) int {
+		if a == b {
+			return 0
+		}
+		if a < b {
+			return -1
+		}
+		return 1
+	})
+	bad.Put(1, 1)
+	if e := bad.Put(nan, 2); e != nil || bad.Len() != 1 {
+		t.Errorf("Put(NaN) = %v with a comparator not ordering NaN, want nil", e)
+	}
+}
@@ -0,0 +1,338 @@
+package skiplist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// SyncPolicy tells when the operation log of a Set is flushed to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs the log before every write of the Set returns,
+	// so that an acknowledged write survives a crash of the machine.
+	SyncAlways SyncPolicy = iota
+	// SyncNone leaves flushing to the operating system, so that an
+	// acknowledged write survives a crash of the process only.
+	// Call SyncLog to flush periodically.
+	SyncNone
+)
+
+// Every log record is an op byte, a uvarint key length, the key, for opSet
+// the score as 8 bytes little endian float64 bits, and the IEEE CRC-32 of
+// the preceding bytes of the record.
+const (
+	opSet byte = 's'
+	opDel byte = 'd'
+)
+
+var (
+	// ErrLogOpen is returned by OpenLog if the Set already has a log.
+	ErrLogOpen = errors.New("skiplist: log already open")
+	// ErrNoLog is returned by the log methods of a Set without a log.
+	ErrNoLog = errors.New("skiplist: no log open")
+	// ErrLogFailed is returned, with the cause, by the writes of a Set
+	// whose log could not be written.
+	ErrLogFailed = errors.New("skiplist: log write failed")
+	// ErrCorruptLog is returned by OpenLog for a log holding a bad record
+	// followed by more data, which a crash while appending cannot leave.
+	ErrCorruptLog = errors.New("skiplist: corrupt log")
+)
+
+// opLog is the append-only operation log of a Set, guarded by the Set lock.
+type opLog struct {
+	path   string
+	policy SyncPolicy
+	f      *os.File
+	buf    []byte // records of the write in progress
+	err    error  // first write error, the Set refuses writes while it is set
+}
+
+func (l *opLog) set(key string, score float64) {
+	l.buf = appendRecord(l.buf, opSet, key, score)
+}
+
+func (l *opLog) del(key string) {
+	l.buf = appendRecord(l.buf, opDel, key, 0)
+}
+
+// commit appends the records of the finished write to the file, and returns
+// an error matching ErrLogFailed if that fails.
+func (l *opLog) commit() error {
+	if len(l.buf) == 0 {
+		return nil
+	}
+	_, err := l.f.Write(l.buf)
+	if err == nil && l.policy == SyncAlways {
+		err = l.f.Sync()
+	}
+	l.buf = l.buf[:0]
+	if err != nil {
+		l.err = err
+		return l.failed()
+	}
+	return nil
+}
+
+// failed returns the error of the writes refused after the log failed.
+func (l *opLog) failed() error {
+	return fmt.Errorf("%w: %v", ErrLogFailed, l.err)
+}
+
+func appendRecord(b []byte, op byte, key string, score float64) []byte {
+	start := len(b)
+	b = append(b, op)
+	b = appendUvarint(b, uint64(len(key)))
+	b = append(b, key...)
+	if op == opSet {
+		b = appendUint64(b, math.Float64bits(score))
+	}
+	var sum [4]byte
+	binary.LittleEndian.PutUint32(sum[:], crc32.ChecksumIEEE(b[start:]))
+	return append(b, sum[:]...)
+}
+
+// OpenLog replays the append-only log at path into s, creating the file if
+// needed, and then appends every later write of s to it. The elements s
+// holds before are kept unless the log deletes them, and the log is then
+// compacted so that it holds them too.
+//
+// A record torn by a crash at the end of the log is discarded, while a bad
+// record followed by more data fails with ErrCorruptLog and leaves s
+// unchanged. If the log cannot be compacted to hold the elements s had
+// before, OpenLog fails with the replayed records applied to s and no log
+// attached. The log grows with every write, see CompactLog.
+//
+// Once a write to the log fails, s refuses writes until CompactLog succeeds
+// or CloseLog is called: the writes returning an error return one matching
+// ErrLogFailed, as does the failed write itself, and the others leave s
+// unchanged.
+func (s *Set) OpenLog(path string, policy SyncPolicy) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.log != nil {
+		return ErrLogOpen
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	records, end, err := readLog(f)
+	if err == nil {
+		err = f.Truncate(end)
+	}
+	if err == nil {
+		_, err = f.Seek(end, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	had := s.skipList.Len() > 0
+	for _, rec := range records {
+		cur, ok := s.dict[rec.key]
+		switch {
+		case rec.op == opDel && ok:
+			s.remove(rec.key)
+		case rec.op == opSet && ok:
+			s.updateScore(rec.key, cur, rec.score)
+		case rec.op == opSet:
+			s.insert(rec.key, rec.score)
+		}
+	}
+	s.log = &opLog{path: path, policy: policy, f: f}
+	if had {
+		// The log does not hold the elements s had before, write them.
+		// Without them the log must not be kept, or replaying it would
+		// lose them.
+		if err := s.rewriteLog(); err != nil {
+			f.Close()
+			s.log = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// logRecord is a record read from a log.
+type logRecord struct {
+	op    byte
+	key   string
+	score float64
+}
+
+// readLog reads the records of f, and returns them with the offset after
+// the last complete record.
+func readLog(f *os.File) ([]logRecord, int64, error) {
+	br := bufio.NewReader(f)
+	r := &countReader{r: br, br: br, crc: crc32.NewIEEE()}
+	var records []logRecord
+	var end int64
+	buf := make([]byte, 8)
+	for {
+		r.crc.Reset()
+		op, err := r.ReadByte()
+		if err == io.EOF {
+			return records, end, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		rec, err := readRecord(r, op, buf)
+		if err == errBadRecord {
+			// Only the last record can be torn by a crash. The file
+			// system may have zero-filled the rest of its space.
+			if rest, err := io.ReadAll(br); err != nil {
+				return nil, 0, err
+			} else if bytes.Count(rest, []byte{0}) != len(rest) {
+				return nil, 0, fmt.Errorf("%w: bad record at offset %d", ErrCorruptLog, end)
+			}
+			return records, end, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		records = append(records, rec)
+		end = r.n
+	}
+}
+
+// errBadRecord is returned by readRecord for a record which is malformed,
+// cut short or fails its checksum.
+var errBadRecord = errors.New("skiplist: bad log record")
+
+// readRecord reads the rest of a record starting with op.
+func readRecord(r *countReader, op byte, buf []byte) (logRecord, error) {
+	// A read error is left for the caller to meet again while checking
+	// the rest of the file.
+	n, err := binary.ReadUvarint(r)
+	if err != nil || (op != opSet && op != opDel) || n > maxKeyLen {
+		return logRecord{}, errBadRecord
+	}
+	key, err := readKey(r, n)
+	if err != nil {
+		return logRecord{}, badOrErr(err)
+	}
+	var score float64
+	if op == opSet {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return logRecord{}, badOrErr(err)
+		}
+		score = math.Float64frombits(binary.LittleEndian.Uint64(buf))
+	}
+	sum := r.crc.Sum32()
+	if _, err := io.ReadFull(r, buf[:4]); err != nil {
+		return logRecord{}, badOrErr(err)
+	}
+	if binary.LittleEndian.Uint32(buf) != sum || math.IsNaN(score) {
+		return logRecord{}, errBadRecord
+	}
+	return logRecord{op: op, key: key, score: score}, nil
+}
+
+// badOrErr returns errBadRecord for the errors of a short record, and err
+// otherwise.
+func badOrErr(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errBadRecord
+	}
+	return err
+}
+
+// CompactLog rewrites the log of s to hold only the current elements.
+// The new log replaces the old one atomically by renaming. It also clears
+// a failure of the log, since the new log holds every element of s.
+func (s *Set) CompactLog() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.log == nil {
+		return ErrNoLog
+	}
+	return s.rewriteLog()
+}
+
+// rewriteLog replaces the log of s with one holding the current elements,
+// the caller must hold the write lock.
+func (s *Set) rewriteLog() error {
+	tmp := s.log.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	var buf []byte
+	for x := s.skipList.Front(); x != nil && err == nil; x = x.Next() {
+		buf = appendRecord(buf[:0], opSet, x.key.key, x.key.score)
+		_, err = w.Write(buf)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.log.path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	syncDir(filepath.Dir(s.log.path))
+	// f is positioned at its end, ready for appending.
+	s.log.f.Close()
+	s.log.f = f
+	s.log.err = nil
+	return nil
+}
+
+// syncDir makes a rename in dir durable, where the platform supports it.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// SyncLog flushes the log of s to stable storage, and returns the error
+// which made the log fail since it was opened or compacted. A failed sync
+// makes the log fail as well.
+func (s *Set) SyncLog() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.log == nil {
+		return ErrNoLog
+	}
+	if s.log.err == nil {
+		s.log.err = s.log.f.Sync()
+	}
+	return s.log.err
+}
+
+// CloseLog flushes and closes the log of s, later writes are not logged and
+// no longer refused. It returns the error which made the log fail, if any.
+func (s *Set) CloseLog() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.log == nil {
+		return ErrNoLog
+	}
+	err := s.log.err
+	if err == nil {
+		err = s.log.f.Sync()
+	}
+	if cerr := s.log.f.Close(); err == nil {
+		err = cerr
+	}
+	s.log = nil
+	return err
+}
@@ -0,0 +1,252 @@
+package skiplist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// The snapshot format written by WriteTo is the magic "ZSET", a version
+// byte, the number of elements as uvarint, then every element in ascending
+// order as a uvarint key length, the key and the score as 8 bytes little
+// endian float64 bits, followed by the IEEE CRC-32 of all preceding bytes.
+const (
+	snapshotMagic   = "ZSET"
+	snapshotVersion = 1
+	// maxKeyLen bounds key lengths read from snapshots and logs. Keys
+	// are read by readKey, so that a corrupt length cannot request an
+	// allocation much larger than the input either.
+	maxKeyLen = 512 << 20
+	// keyChunk is the longest key allocated at once by readKey.
+	keyChunk = 64 << 10
+)
+
+// ErrCorrupt is returned when a snapshot is malformed or fails its checksum.
+var ErrCorrupt = errors.New("skiplist: corrupt snapshot")
+
+// WriteTo writes a binary snapshot of s to w, it implements io.WriterTo.
+func (s *Set) WriteTo(w io.Writer) (int64, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	crc := crc32.NewIEEE()
+	out := io.MultiWriter(bw, crc)
+	buf := make([]byte, 0, 64)
+	buf = append(buf, snapshotMagic...)
+	buf = append(buf, snapshotVersion)
+	buf = appendUvarint(buf, uint64(s.skipList.Len()))
+	if _, err := out.Write(buf); err != nil {
+		return cw.n, err
+	}
+	for x := s.skipList.Front(); x != nil; x = x.Next() {
+		buf = appendUvarint(buf[:0], uint64(len(x.key.key)))
+		buf = append(buf, x.key.key...)
+		buf = appendUint64(buf, math.Float64bits(x.key.score))
+		if _, err := out.Write(buf); err != nil {
+			return cw.n, err
+		}
+	}
+	buf = buf[:4]
+	binary.LittleEndian.PutUint32(buf, crc.Sum32())
+	if _, err := bw.Write(buf); err != nil {
+		return cw.n, err
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ReadFrom replaces the elements of s with a snapshot written by WriteTo,
+// it implements io.ReaderFrom. s is left unchanged if the snapshot cannot be
+// read. If r is not an io.ByteReader, it may be read past the snapshot.
+func (s *Set) ReadFrom(r io.Reader) (_ int64, err error) {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		buffered := bufio.NewReader(r)
+		r, br = buffered, buffered
+	}
+	cr := &countReader{r: r, br: br, crc: crc32.NewIEEE()}
+	nodes, err := readSnapshot(cr)
+	if err != nil {
+		return cr.n, err
+	}
+	if err := s.lockWrite(); err != nil {
+		return cr.n, err
+	}
+	defer s.unlock(&err)
+	s.replace(nodes)
+	return cr.n, nil
+}
+
+func readSnapshot(r *countReader) ([]Node, error) {
+	head := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, noEOF(err)
+	}
+	if string(head[:len(snapshotMagic)]) != snapshotMagic || head[len(snapshotMagic)] != snapshotVersion {
+		return nil, ErrCorrupt
+	}
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	var nodes []Node
+	buf := make([]byte, 8)
+	for i := uint64(0); i < count; i++ {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		if n > maxKeyLen {
+			return nil, ErrCorrupt
+		}
+		key, err := readKey(r, n)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, noEOF(err)
+		}
+		score := math.Float64frombits(binary.LittleEndian.Uint64(buf))
+		if math.IsNaN(score) {
+			return nil, ErrCorrupt
+		}
+		nodes = append(nodes, Node{Key: key, Score: score})
+	}
+	sum := r.crc.Sum32()
+	if _, err := io.ReadFull(r, buf[:4]); err != nil {
+		return nil, noEOF(err)
+	}
+	if binary.LittleEndian.Uint32(buf) != sum {
+		return nil, ErrCorrupt
+	}
+	return nodes, nil
+}
+
+// readKey reads a key of n bytes from r. Keys longer than keyChunk grow as
+// their bytes arrive instead of being allocated upfront, so that memory
+// follows the input.
+func readKey(r io.Reader, n uint64) (string, error) {
+	if n <= keyChunk {
+		key := make([]byte, n)
+		if _, err := io.ReadFull(r, key); err != nil {
+			return "", err
+		}
+		return string(key), nil
+	}
+	var b bytes.Buffer
+	if _, err := io.CopyN(&b, r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(b, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], v)
+	return append(b, tmp[:]...)
+}
+
+// noEOF turns io.EOF into io.ErrUnexpectedEOF, as a snapshot never ends early.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// MarshalJSON encodes s as an array of Node in ascending order.
+func (s *Set) MarshalJSON() ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	nodes := make([]Node, 0, s.skipList.Len())
+	for x := s.skipList.Front(); x != nil; x = x.Next() {
+		nodes = append(nodes, toNode(x))
+	}
+	return json.Marshal(nodes)
+}
+
+// UnmarshalJSON replaces the elements of s with an array of Node.
+// s must be created by NewSet.
+func (s *Set) UnmarshalJSON(data []byte) (err error) {
+	var nodes []Node
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return err
+	}
+	if err := s.lockWrite(); err != nil {
+		return err
+	}
+	defer s.unlock(&err)
+	s.replace(nodes)
+	return nil
+}
+
+// replace makes nodes the elements of s, the caller must hold the write lock.
+// If a key appears more than once, its last score wins.
+func (s *Set) replace(nodes []Node) {
+	keep := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		keep[n.Key] = true
+	}
+	for key := range s.dict {
+		if !keep[key] {
+			s.remove(key)
+		}
+	}
+	for _, n := range nodes {
+		if cur, ok := s.dict[n.Key]; ok {
+			s.updateScore(n.Key, cur, n.Score)
+		} else {
+			s.insert(n.Key, n.Score)
+		}
+	}
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// countReader counts and checksums the bytes read through it.
+type countReader struct {
+	r   io.Reader
+	br  io.ByteReader
+	crc hash.Hash32
+	n   int64
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	r.crc.Write(p[:n])
+	return n, err
+}
+
+func (r *countReader) ReadByte() (byte, error) {
+	b, err := r.br.ReadByte()
+	if err == nil {
+		r.n++
+		r.crc.Write([]byte{b})
+	}
+	return b, err
+}
@@ -0,0 +1,318 @@
+package skiplist
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	s, nodes := newTestSet(100, 8)
+	s.Set("inf", math.Inf(1))
+	s.Set("", -1.5)
+	nodes = dump(s)
+	var buf bytes.Buffer
+	n, err := s.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("WriteTo = (%d, %v), buffer has %d bytes", n, err, buf.Len())
+	}
+	data := buf.Bytes()
+	restored := NewSet()
+	restored.Set("stale", 1)
+	buf.WriteString("trailing data")
+	if n, err := restored.ReadFrom(&buf); err != nil || n != int64(len(data)) {
+		t.Fatalf("ReadFrom = (%d, %v), want %d bytes", n, err, len(data))
+	}
+	if buf.String() != "trailing data" {
+		t.Errorf("ReadFrom read past the snapshot, left %q", buf.String())
+	}
+	if got := dump(restored); !reflect.DeepEqual(got, nodes) {
+		t.Fatalf("restored = %v, want %v", got, nodes)
+	}
+	if rank, _ := restored.GetRank(nodes[10].Key); rank != 11 {
+		t.Errorf("GetRank after restore = %d, want 11", rank)
+	}
+
+	for _, bad := range [][]byte{
+		data[:len(data)-1],
+		append([]byte("XSET"), data[4:]...),
+		append(append([]byte{}, data[:len(data)-1]...), data[len(data)-1]^1),
+	} {
+		s := NewSet()
+		s.Set("kept", 1)
+		if _, err := s.ReadFrom(bytes.NewReader(bad)); err != ErrCorrupt && err != io.ErrUnexpectedEOF {
+			t.Errorf("ReadFrom of a bad snapshot = %v", err)
+		}
+		if !s.HasKey("kept") || s.GetLength() != 1 {
+			t.Error("a failed ReadFrom changed the set")
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	s := newSetOf(map[string]float64{"a": 2, "b": 1})
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `[{"key":"b","score":1},{"key":"a","score":2}]` {
+		t.Errorf("Marshal = %s", data)
+	}
+	restored := NewSet()
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatal(err)
+	}
+	if got := dump(restored); !reflect.DeepEqual(got, dump(s)) {
+		t.Errorf("Unmarshal = %v, want %v", got, dump(s))
+	}
+}
+
+func TestLogReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "set.log")
+	s := NewSet()
+	if err := s.OpenLog(path, SyncAlways); err != nil {
+		t.Fatal(err)
+	}
+	s.Set("a", 1)
+	s.Set("b", 2)
+	s.IncrBy("a", 10)
+	s.Add("c", 3, AddNX)
+	s.Del("b")
+	s.Set("d", 4)
+	s.RemoveRangeByScore(ScoreRange{Min: 4, Max: 4})
+	want := dump(s)
+	if err := s.CloseLog(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash in the middle of writing a record.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(appendRecord(nil, opSet, "torn", 5)[:6])
+	f.Close()
+
+	recovered := NewSet()
+	if err := recovered.OpenLog(path, SyncAlways); err != nil {
+		t.Fatal(err)
+	}
+	if got := dump(recovered); !reflect.DeepEqual(got, want) {
+		t.Fatalf("recovered = %v, want %v", got, want)
+	}
+	recovered.Set("e", 5)
+	want = dump(recovered)
+	if err := recovered.CompactLog(); err != nil {
+		t.Fatal(err)
+	}
+	recovered.Set("f", 60)
+	want = append(want, Node{"f", 60})
+	if err := recovered.CloseLog(); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+
+	again := NewSet()
+	if err := again.OpenLog(path, SyncNone); err != nil {
+		t.Fatal(err)
+	}
+	defer again.CloseLog()
+	if got := dump(again); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after compaction = %v, want %v", got, want)
+	}
+	if size := int64(len(want)) * int64(len(appendRecord(nil, opSet, "a", 0))); info.Size() != size {
+		t.Errorf("compacted log has %d bytes, want %d", info.Size(), size)
+	}
+	if err := again.OpenLog(path, SyncNone); err != ErrLogOpen {
+		t.Errorf("second OpenLog = %v, want ErrLogOpen", err)
+	}
+}
+
+func TestLogFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "set.log")
+	s := NewSet()
+	if err := s.OpenLog(path, SyncAlways); err != nil {
+		t.Fatal(err)
+	}
+	s.Set("a", 1)
+	s.Set("b", 2)
+
+	// Make the next write of the log fail.
+	s.log.f.Close()
+	if err := s.Del("a"); !errors.Is(err, ErrLogFailed) {
+		t.Fatalf("Del with a failing log = %v, want ErrLogFailed", err)
+	}
+	if x := s.Set("c", 3); x != nil || s.HasKey("c") {
+		t.Error("Set was applied after the log failed")
+	}
+	if _, err := s.IncrBy("b", 1); !errors.Is(err, ErrLogFailed) {
+		t.Errorf("IncrBy after the log failed = %v, want ErrLogFailed", err)
+	}
+	if _, err := s.Add("c", 3, 0); !errors.Is(err, ErrLogFailed) {
+		t.Errorf("Add after the log failed = %v, want ErrLogFailed", err)
+	}
+	if nodes := s.PopMin(1); nodes != nil || s.RemoveRangeByRank(1, -1) != 0 {
+		t.Error("removal was applied after the log failed")
+	}
+	if got, want := dump(s), []Node{{"b", 2}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("elements = %v, want %v", got, want)
+	}
+	if err := s.SyncLog(); err == nil {
+		t.Error("SyncLog of a failed log succeeded")
+	}
+
+	// Compaction writes the current elements to a new log and accepts
+	// writes again.
+	if err := s.CompactLog(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.IncrBy("b", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CloseLog(); err != nil {
+		t.Fatal(err)
+	}
+	recovered := NewSet()
+	if err := recovered.OpenLog(path, SyncNone); err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.CloseLog()
+	if got, want := dump(recovered), []Node{{"b", 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recovered = %v, want %v", got, want)
+	}
+}
+
+func TestOpenLogKeepsElements(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "set.log")
+	s := NewSet()
+	s.OpenLog(path, SyncNone)
+	s.Set("logged", 1)
+	s.Set("deleted", 2)
+	s.Del("deleted")
+	s.CloseLog()
+
+	s = NewSet()
+	s.Set("before", 3)
+	s.Set("deleted", 4)
+	if err := s.OpenLog(path, SyncNone); err != nil {
+		t.Fatal(err)
+	}
+	want := []Node{{"logged", 1}, {"before", 3}}
+	if got := dump(s); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after OpenLog = %v, want %v", got, want)
+	}
+	s.CloseLog()
+
+	recovered := NewSet()
+	if err := recovered.OpenLog(path, SyncNone); err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.CloseLog()
+	if got := dump(recovered); !reflect.DeepEqual(got, want) {
+		t.Errorf("recovered = %v, want %v", got, want)
+	}
+}
+
+func TestLogCorruption(t *testing.T) {
+	var log []byte
+	for _, key := range []string{"a", "b", "c"} {
+		log = appendRecord(log, opSet, key, 1)
+	}
+	size := len(appendRecord(nil, opSet, "a", 1))
+	cases := []struct {
+		name string
+		data []byte
+		want []Node
+		err  error
+	}{
+		{"torn", log[:len(log)-3], []Node{{"a", 1}, {"b", 1}}, nil},
+		{"zero filled", append(append([]byte{}, log[:2*size+2]...), make([]byte, 100)...), []Node{{"a", 1}, {"b", 1}}, nil},
+		{"bad checksum at the end", flip(log, len(log)-1), []Node{{"a", 1}, {"b", 1}}, nil},
+		{"bad checksum in the middle", flip(log, size-1), nil, ErrCorruptLog},
+		{"bad op in the middle", flip(log, size), nil, ErrCorruptLog},
+		{"NaN score", appendRecord(nil, opSet, "nan", math.NaN()), nil, nil},
+	}
+	for _, c := range cases {
+		path := filepath.Join(t.TempDir(), "set.log")
+		if err := os.WriteFile(path, c.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		s := NewSet()
+		s.Set("kept", 0)
+		err := s.OpenLog(path, SyncNone)
+		if !errors.Is(err, c.err) {
+			t.Errorf("%s: OpenLog = %v, want %v", c.name, err, c.err)
+			continue
+		}
+		want := append([]Node{{"kept", 0}}, c.want...)
+		if got := dump(s); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: elements = %v, want %v", c.name, got, want)
+		}
+		if err == nil {
+			s.CloseLog()
+		}
+	}
+}
+
+func TestOpenLogRewriteFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "set.log")
+	// Make the compaction of the log fail.
+	if err := os.Mkdir(path+".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := NewSet()
+	s.Set("old", 1)
+	if err := s.OpenLog(path, SyncNone); err == nil {
+		t.Fatal("OpenLog succeeded without writing the elements of the set")
+	}
+	if err := s.CloseLog(); err != ErrNoLog {
+		t.Errorf("CloseLog after a failed OpenLog = %v, want ErrNoLog", err)
+	}
+	s.Set("new", 2)
+	if err := s.OpenLog(filepath.Join(t.TempDir(), "set.log"), SyncNone); err != nil {
+		t.Fatalf("OpenLog after a failed one: %v", err)
+	}
+	s.CloseLog()
+}
+
+func TestCorruptKeyLength(t *testing.T) {
+	var snapshot bytes.Buffer
+	newSetOf(map[string]float64{"a": 1}).WriteTo(&snapshot)
+	data := snapshot.Bytes()
+	// The key length follows the magic, the version and the count.
+	data = append(append(append([]byte{}, data[:6]...), appendUvarint(nil, maxKeyLen)...), data[7:]...)
+	log := append(appendUvarint([]byte{opSet}, maxKeyLen), "key"...)
+	path := filepath.Join(t.TempDir(), "set.log")
+	if err := os.WriteFile(path, log, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := NewSet().ReadFrom(bytes.NewReader(data)); err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadFrom = %v, want io.ErrUnexpectedEOF", err)
+	}
+	s := NewSet()
+	if err := s.OpenLog(path, SyncNone); err != nil {
+		t.Errorf("OpenLog of a torn record: %v", err)
+	}
+	s.CloseLog()
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("reading keys of a corrupt length allocated %d bytes", n)
+	}
+}
+
+// flip returns a copy of b with the bits of b[i] inverted.
+func flip(b []byte, i int) []byte {
+	b = append([]byte{}, b...)
+	b[i] ^= 0xff
+	return b
+}
@@ -0,0 +1,93 @@
+package skiplist
+
+import "context"
+
+// PopMin removes and returns up to n elements with the lowest scores in
+// ascending order, like redis ZPOPMIN.
+func (s *Set) PopMin(n int64) []Node {
+	if s.lockWrite() != nil {
+		return nil
+	}
+	defer s.unlock(nil)
+	return s.popMin(n)
+}
+
+// PopMax removes and returns up to n elements with the highest scores in
+// descending order, like redis ZPOPMAX.
+func (s *Set) PopMax(n int64) []Node {
+	if s.lockWrite() != nil {
+		return nil
+	}
+	defer s.unlock(nil)
+	return s.popMax(n)
+}
+
+// BPopMin removes and returns the element with the lowest score, waiting
+// until the set has an element or ctx is done, like redis BZPOPMIN.
+func (s *Set) BPopMin(ctx context.Context) (Node, error) {
+	return s.bpop(ctx, s.popMin)
+}
+
+// BPopMax removes and returns the element with the highest score, waiting
+// until the set has an element or ctx is done, like redis BZPOPMAX.
+func (s *Set) BPopMax(ctx context.Context) (Node, error) {
+	return s.bpop(ctx, s.popMax)
+}
+
+func (s *Set) bpop(ctx context.Context, pop func(n int64) []Node) (Node, error) {
+	for {
+		if err := s.lockWrite(); err != nil {
+			return Node{}, err
+		}
+		if nodes := pop(1); len(nodes) > 0 {
+			var err error
+			s.unlock(&err)
+			return nodes[0], err
+		}
+		if s.added == nil {
+			s.added = make(chan struct{})
+		}
+		added := s.added
+		s.lock.Unlock()
+		select {
+		case <-added:
+		case <-ctx.Done():
+			return Node{}, ctx.Err()
+		}
+	}
+}
+
+// popMin removes the first n elements, the caller must hold the write lock.
+func (s *Set) popMin(n int64) []Node {
+	if n <= 0 || s.skipList.Len() == 0 {
+		return nil
+	}
+	if n > s.skipList.Len() {
+		n = s.skipList.Len()
+	}
+	nodes := make([]Node, 0, n)
+	s.skipList.removeRangeByRank(1, n, func(x *node) {
+		nodes = append(nodes, toNode(x))
+		s.forget(x)
+	})
+	return nodes
+}
+
+// popMax removes the last n elements, the caller must hold the write lock.
+func (s *Set) popMax(n int64) []Node {
+	if n <= 0 || s.skipList.Len() == 0 {
+		return nil
+	}
+	length := s.skipList.Len()
+	if n > length {
+		n = length
+	}
+	nodes := make([]Node, n)
+	i := n
+	s.skipList.removeRangeByRank(length-n+1, length, func(x *node) {
+		i--
+		nodes[i] = toNode(x)
+		s.forget(x)
+	})
+	return nodes
+}
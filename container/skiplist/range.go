@@ -0,0 +1,209 @@
+package skiplist
+
+// rangeSpec is an interval of the Set order, see ScoreRange and LexRange.
+type rangeSpec interface {
+	isEmpty() bool
+	// gteMin reports whether m is not below the lower end of the range.
+	gteMin(m member) bool
+	// lteMax reports whether m is not above the upper end of the range.
+	lteMax(m member) bool
+}
+
+// ScoreRange is an interval of scores, like the min and max arguments of
+// redis ZRANGEBYSCORE. Both ends are inclusive unless marked exclusive.
+type ScoreRange struct {
+	Min          float64
+	Max          float64
+	MinExclusive bool
+	MaxExclusive bool
+}
+
+func (r ScoreRange) isEmpty() bool {
+	return r.Min > r.Max || (r.Min == r.Max && (r.MinExclusive || r.MaxExclusive))
+}
+
+func (r ScoreRange) gteMin(m member) bool {
+	if r.MinExclusive {
+		return m.score > r.Min
+	}
+	return m.score >= r.Min
+}
+
+func (r ScoreRange) lteMax(m member) bool {
+	if r.MaxExclusive {
+		return m.score < r.Max
+	}
+	return m.score <= r.Max
+}
+
+// isInRange reports whether some part of the set is in range r.
+func (s *Set) isInRange(r rangeSpec) bool {
+	sl := s.skipList
+	if r.isEmpty() || sl.Len() == 0 {
+		return false
+	}
+	return r.gteMin(sl.Back().key) && r.lteMax(sl.Front().key)
+}
+
+// firstInRange returns the first node in range r and its rank,
+// or nil if there is none.
+func (s *Set) firstInRange(r rangeSpec) (*node, int64) {
+	if !s.isInRange(r) {
+		return nil, 0
+	}
+	x, rank := s.skipList.seekFirst(func(m member) bool { return !r.gteMin(m) })
+	if x == nil || !r.lteMax(x.key) {
+		return nil, 0
+	}
+	return x, rank
+}
+
+// lastInRange returns the last node in range r and its rank,
+// or nil if there is none.
+func (s *Set) lastInRange(r rangeSpec) (*node, int64) {
+	if !s.isInRange(r) {
+		return nil, 0
+	}
+	x, rank := s.skipList.seekLast(r.lteMax)
+	if x == nil || !r.gteMin(x.key) {
+		return nil, 0
+	}
+	return x, rank
+}
+
+// RangeByRank returns the elements ranked from start to stop, both inclusive,
+// like redis ZRANGE. Ranks start from 1, and negative ranks count from the
+// end, so RangeByRank(1, -1, false) returns the whole set.
+// If desc is true, ranks are in descending order like redis ZREVRANGE.
+func (s *Set) RangeByRank(start, stop int64, desc bool) []Node {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	start, stop, ok := s.skipList.rankRange(start, stop)
+	if !ok {
+		return nil
+	}
+	nodes := make([]Node, 0, stop-start+1)
+	if desc {
+		x := s.skipList.GetByRank(s.skipList.Len() - start + 1)
+		for i := start; i <= stop; i++ {
+			nodes = append(nodes, toNode(x))
+			x = x.Prev()
+		}
+	} else {
+		x := s.skipList.GetByRank(start)
+		for i := start; i <= stop; i++ {
+			nodes = append(nodes, toNode(x))
+			x = x.Next()
+		}
+	}
+	return nodes
+}
+
+// RangeByScore returns the elements with a score in r, like redis
+// ZRANGEBYSCORE with LIMIT offset count. It skips offset elements and returns
+// at most limit elements, a negative limit means no limit.
+// If desc is true, the elements are returned from the highest score down.
+func (s *Set) RangeByScore(r ScoreRange, offset, limit int64, desc bool) []Node {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.rangeBy(r, offset, limit, desc)
+}
+
+// rangeBy returns the elements in range r, see RangeByScore.
+func (s *Set) rangeBy(r rangeSpec, offset, limit int64, desc bool) []Node {
+	if offset < 0 || limit == 0 {
+		return nil
+	}
+	var x *node
+	var rank int64
+	if desc {
+		if x, rank = s.lastInRange(r); x == nil {
+			return nil
+		}
+		if offset > 0 {
+			x = s.skipList.GetByRank(rank - offset)
+		}
+	} else {
+		if x, rank = s.firstInRange(r); x == nil {
+			return nil
+		}
+		if offset > 0 {
+			x = s.skipList.GetByRank(rank + offset)
+		}
+	}
+	var nodes []Node
+	for x != nil && (limit < 0 || int64(len(nodes)) < limit) {
+		if desc {
+			if !r.gteMin(x.key) {
+				break
+			}
+			nodes = append(nodes, toNode(x))
+			x = x.Prev()
+		} else {
+			if !r.lteMax(x.key) {
+				break
+			}
+			nodes = append(nodes, toNode(x))
+			x = x.Next()
+		}
+	}
+	return nodes
+}
+
+// CountInScore returns the number of elements with a score in r, like redis ZCOUNT.
+func (s *Set) CountInScore(r ScoreRange) int64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.count(r)
+}
+
+// count returns the number of elements in range r.
+func (s *Set) count(r rangeSpec) int64 {
+	_, first := s.firstInRange(r)
+	if first == 0 {
+		return 0
+	}
+	_, last := s.lastInRange(r)
+	return last - first + 1
+}
+
+// RemoveRangeByRank removes the elements ranked from start to stop, both
+// inclusive, like redis ZREMRANGEBYRANK. Ranks follow RangeByRank.
+// It returns the number of removed elements.
+func (s *Set) RemoveRangeByRank(start, stop int64) int64 {
+	if s.lockWrite() != nil {
+		return 0
+	}
+	defer s.unlock(nil)
+	start, stop, ok := s.skipList.rankRange(start, stop)
+	if !ok {
+		return 0
+	}
+	return s.skipList.removeRangeByRank(start, stop, s.forget)
+}
+
+// RemoveRangeByScore removes the elements with a score in r,
+// like redis ZREMRANGEBYSCORE. It returns the number of removed elements.
+func (s *Set) RemoveRangeByScore(r ScoreRange) int64 {
+	if s.lockWrite() != nil {
+		return 0
+	}
+	defer s.unlock(nil)
+	return s.removeRange(r)
+}
+
+// removeRange removes the elements in range r, the caller must hold the write lock.
+func (s *Set) removeRange(r rangeSpec) int64 {
+	if !s.isInRange(r) {
+		return 0
+	}
+	return s.skipList.removeRange(func(m member) bool { return !r.gteMin(m) }, r.lteMax, s.forget)
+}
+
+// forget deletes the key of a node removed from the list.
+func (s *Set) forget(x *node) {
+	delete(s.dict, x.key.key)
+	if s.log != nil {
+		s.log.del(x.key.key)
+	}
+}
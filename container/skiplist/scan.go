@@ -0,0 +1,63 @@
+package skiplist
+
+import "math"
+
+// Cursor is a position in a Set for Scan, just after (or before, scanning
+// in descending order) the element with Score and Key. The element does
+// not need to exist anymore, so a cursor stays valid while the Set changes.
+type Cursor struct {
+	Score float64 `json:"score"`
+	Key   string  `json:"key"`
+}
+
+func (c *Cursor) member() member {
+	return member{c.Score, c.Key}
+}
+
+// Scan returns a page of up to count elements following the cursor after,
+// or from the start if after is nil, and the cursor of the next page, which
+// is nil if there are no more elements. If desc is true, the elements are
+// scanned from the highest score down.
+//
+// Unlike ranks, cursors are not shifted by elements added or removed between
+// pages: every element present during the whole scan is returned exactly
+// once, unless its score changes.
+func (s *Set) Scan(after *Cursor, count int64, desc bool) ([]Node, *Cursor) {
+	return s.ScanByScore(ScoreRange{Min: math.Inf(-1), Max: math.Inf(1)}, after, count, desc)
+}
+
+// ScanByScore is like Scan, but only returns elements with a score in r.
+func (s *Set) ScanByScore(r ScoreRange, after *Cursor, count int64, desc bool) ([]Node, *Cursor) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if count <= 0 {
+		return nil, nil
+	}
+	var x *node
+	switch {
+	case after == nil && desc:
+		x, _ = s.lastInRange(r)
+	case after == nil:
+		x, _ = s.firstInRange(r)
+	case desc:
+		c := after.member()
+		x, _ = s.skipList.seekLast(func(m member) bool { return compareMembers(m, c) < 0 && r.lteMax(m) })
+	default:
+		c := after.member()
+		x, _ = s.skipList.seekFirst(func(m member) bool { return compareMembers(m, c) <= 0 || !r.gteMin(m) })
+	}
+	var nodes []Node
+	for x != nil && r.gteMin(x.key) && r.lteMax(x.key) {
+		if int64(len(nodes)) == count {
+			last := nodes[len(nodes)-1]
+			return nodes, &Cursor{Score: last.Score, Key: last.Key}
+		}
+		nodes = append(nodes, toNode(x))
+		if desc {
+			x = x.Prev()
+		} else {
+			x = x.Next()
+		}
+	}
+	return nodes, nil
+}
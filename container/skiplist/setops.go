@@ -0,0 +1,180 @@
+package skiplist
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// Aggregate is the way Union and Intersect combine the scores of an element
+// found in several sets, like the AGGREGATE option of redis ZUNIONSTORE.
+type Aggregate int
+
+const (
+	AggregateSum Aggregate = iota
+	AggregateMin
+	AggregateMax
+)
+
+// ErrWeights is returned when the number of weights does not match the number of sets.
+var ErrWeights = errors.New("skiplist: number of weights does not match number of sets")
+
+// Union returns a new Set holding the elements of all sets, like redis
+// ZUNIONSTORE. The score of an element in each set is multiplied by the
+// weight of that set, and the weighted scores are combined by aggregate.
+// If weights is nil, every weight is 1. A nil set is treated as empty, like
+// a missing key in redis.
+func Union(sets []*Set, weights []float64, aggregate Aggregate) (*Set, error) {
+	if weights != nil && len(weights) != len(sets) {
+		return nil, ErrWeights
+	}
+	defer rLockSets(sets)()
+	scores := make(map[string]float64)
+	for i, s := range sets {
+		if s == nil {
+			continue
+		}
+		w := weightOf(weights, i)
+		for key, score := range s.dict {
+			if cur, ok := scores[key]; ok {
+				scores[key] = aggregate.combine(cur, weighted(score, w))
+			} else {
+				scores[key] = weighted(score, w)
+			}
+		}
+	}
+	return newSetFrom(scores), nil
+}
+
+// Intersect returns a new Set holding the elements found in every set, like
+// redis ZINTERSTORE. Scores are weighted and combined as in Union, and a nil
+// set makes the result empty.
+func Intersect(sets []*Set, weights []float64, aggregate Aggregate) (*Set, error) {
+	if weights != nil && len(weights) != len(sets) {
+		return nil, ErrWeights
+	}
+	for _, s := range sets {
+		if s == nil {
+			return NewSet(), nil
+		}
+	}
+	if len(sets) == 0 {
+		return NewSet(), nil
+	}
+	defer rLockSets(sets)()
+	// Probe the other sets with the keys of the smallest one.
+	smallest := 0
+	for i, s := range sets {
+		if len(s.dict) < len(sets[smallest].dict) {
+			smallest = i
+		}
+	}
+	scores := make(map[string]float64)
+	for key := range sets[smallest].dict {
+		var result float64
+		found := true
+		for i, s := range sets {
+			score, ok := s.dict[key]
+			if !ok {
+				found = false
+				break
+			}
+			if i == 0 {
+				result = weighted(score, weightOf(weights, i))
+			} else {
+				result = aggregate.combine(result, weighted(score, weightOf(weights, i)))
+			}
+		}
+		if found {
+			scores[key] = result
+		}
+	}
+	return newSetFrom(scores), nil
+}
+
+// Diff returns a new Set holding the elements of the first set which are
+// not in any of the others, with their scores in the first set, like redis ZDIFFSTORE.
+// A nil set is treated as empty.
+func Diff(sets []*Set) *Set {
+	if len(sets) == 0 || sets[0] == nil {
+		return NewSet()
+	}
+	defer rLockSets(sets)()
+	scores := make(map[string]float64)
+	for key, score := range sets[0].dict {
+		found := false
+		for _, s := range sets[1:] {
+			if s == nil {
+				continue
+			}
+			if _, found = s.dict[key]; found {
+				break
+			}
+		}
+		if !found {
+			scores[key] = score
+		}
+	}
+	return newSetFrom(scores)
+}
+
+func (a Aggregate) combine(x, y float64) float64 {
+	switch a {
+	case AggregateMin:
+		return math.Min(x, y)
+	case AggregateMax:
+		return math.Max(x, y)
+	}
+	if sum := x + y; !math.IsNaN(sum) {
+		return sum
+	}
+	// +inf and -inf add up to 0, as in redis.
+	return 0
+}
+
+func weightOf(weights []float64, i int) float64 {
+	if weights == nil {
+		return 1
+	}
+	return weights[i]
+}
+
+// weighted returns score multiplied by weight, where 0 times infinity is 0.
+func weighted(score, weight float64) float64 {
+	if v := score * weight; !math.IsNaN(v) {
+		return v
+	}
+	return 0
+}
+
+// rLockSets read locks each distinct non-nil set of sets in the order of
+// their ids, so that concurrent calls on overlapping sets cannot deadlock,
+// and returns a function to unlock them.
+func rLockSets(sets []*Set) (unlock func()) {
+	locked := make([]*Set, 0, len(sets))
+	seen := make(map[*Set]bool, len(sets))
+	for _, s := range sets {
+		if s != nil && !seen[s] {
+			seen[s] = true
+			locked = append(locked, s)
+		}
+	}
+	sort.Slice(locked, func(i, j int) bool { return locked[i].id < locked[j].id })
+	for _, s := range locked {
+		s.lock.RLock()
+	}
+	return func() {
+		for i := len(locked) - 1; i >= 0; i-- {
+			locked[i].lock.RUnlock()
+		}
+	}
+}
+
+// newSetFrom returns a new Set holding the keys of scores.
+func newSetFrom(scores map[string]float64) *Set {
+	s := NewSet()
+	for key, score := range scores {
+		s.insert(key, score)
+	}
+	return s
+}
@@ -2,107 +2,129 @@ package skiplist
 
 import (
 	"errors"
-	//"log"
-	"math/rand"
-	"sync"
-	"time"
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	"github.com/funbytes/modern-go/internal/rwmutex"
 )
 
 const DefaultMaxLevel = 32
 const p = 0.25
 
-type node struct {
-	key      string
-	score    float64
-	backward *node
-	level    [DefaultMaxLevel]struct {
-		forward *node
-		span    int64
+// ErrNotFound is returned, wrapped with the key, by the lookups of a key
+// which does not exist.
+var ErrNotFound = errors.New("skiplist: key not found")
+
+// notFound returns an error matching ErrNotFound for key.
+func notFound(key string) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, key)
+}
+
+// member is the key of a Set element in its skip list, ordered by score then key.
+type member struct {
+	score float64
+	key   string
+}
+
+func compareMembers(a, b member) int {
+	if a.score < b.score {
+		return -1
 	}
+	if a.score > b.score {
+		return 1
+	}
+	return Compare(a.key, b.key)
 }
 
+type node = Element[member, struct{}]
+
 type Node struct {
 	Key   string  `json:"key"`
 	Score float64 `json:"score"`
 }
 
-type skipList struct {
-	header *node
-	tail   *node
-	length int64
-	level  int
-}
-
+// Set is a sorted set like redis ZSET, a thin facade over SkipList.
 type Set struct {
-	dict     map[string]*float64
-	skipList *skipList
-	lock     *sync.RWMutex
-}
-
-func newSkipList() *skipList {
-	sl := new(skipList)
-	sl.level = 1
-	sl.length = 0
-	sl.header = new(node)
-	for j := 0; j < DefaultMaxLevel; j++ {
-		sl.header.level[j].forward = nil
-		sl.header.level[j].span = 0
-	}
-	sl.header.backward = nil
-	sl.tail = nil
-	return sl
+	id       uint64 // orders locking of several sets
+	dict     map[string]float64
+	skipList *SkipList[member, struct{}]
+	lock     *rwmutex.RWMutex
+	log      *opLog // nil unless OpenLog is called
+	// added is closed when a key is added, to wake blocked pops.
+	added chan struct{}
 }
 
-func NewSet() *Set {
-	sl := newSkipList()
-	sl.level = 1
+var lastSetID uint64
+
+// NewSet creates and returns an empty Set, opts configure its skip list.
+func NewSet(opts ...Option) *Set {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
 	return &Set{
-		dict:     make(map[string]*float64),
-		skipList: sl,
-		lock:     new(sync.RWMutex),
+		id:       atomic.AddUint64(&lastSetID, 1),
+		dict:     make(map[string]float64),
+		skipList: New[member, struct{}](compareMembers, opts...),
+		lock:     rwmutex.New(!o.unsafe),
 	}
 }
 
-func (s *Set) createNode(key string, score float64) *node {
-	n := new(node)
-	n.key = key
-	n.score = score
-	return n
+func toNode(x *node) Node {
+	return Node{Key: x.key.key, Score: x.key.score}
 }
 
 // 根据排名获取node 按升序获得 rank 从1开始
+// It returns a zero Node for a rank out of range, see ElementByRankASC.
 func (s *Set) GetElementByRankASC(rank int64) Node {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
-	var n Node
-	x := s.skipList.header
-	var traversed int64 = 0
-	for i := s.skipList.level - 1; i >= 0; i-- {
-		for x.level[i].forward != nil && (traversed+x.level[i].span) <= rank {
-			traversed += x.level[i].span
-			x = x.level[i].forward
-		}
-		if traversed == rank {
-			n.Key = x.key
-			n.Score = x.score
-			return n
-		}
-	}
+	n, _ := s.ElementByRankASC(rank)
 	return n
 }
 
 // 根据排名获取node 按降序获得 rank 从1开始
+// It returns a zero Node for a rank out of range, see ElementByRankDESC.
 func (s *Set) GetElementByRankDESC(rank int64) Node {
-	rank = s.GetLenth() - rank + 1
-	return s.GetElementByRankASC(rank)
+	n, _ := s.ElementByRankDESC(rank)
+	return n
+}
+
+// ElementByRankASC returns the element of 1-based rank in ascending order,
+// and false if rank is not in [1, GetLength()].
+func (s *Set) ElementByRankASC(rank int64) (Node, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.elementByRank(rank)
+}
+
+// ElementByRankDESC returns the element of 1-based rank in descending
+// order, and false if rank is not in [1, GetLength()].
+func (s *Set) ElementByRankDESC(rank int64) (Node, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if rank < 1 {
+		return Node{}, false
+	}
+	return s.elementByRank(s.skipList.Len() - rank + 1)
 }
+
+// elementByRank returns the element of 1-based rank in ascending order,
+// the caller must hold the lock.
+func (s *Set) elementByRank(rank int64) (Node, bool) {
+	if x := s.skipList.GetByRank(rank); x != nil {
+		return toNode(x), true
+	}
+	return Node{}, false
+}
+
+// GetScore returns the score of key, or an error matching ErrNotFound.
 func (s *Set) GetScore(key string) (float64, error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
-	if _, ok := s.dict[key]; !ok {
-		return 0, errors.New("not find key " + key)
+	score, ok := s.dict[key]
+	if !ok {
+		return 0, notFound(key)
 	}
-	score := *s.dict[key]
 	return score, nil
 }
 
@@ -110,79 +132,105 @@ func (s *Set) GetScore(key string) (float64, error) {
 func (s *Set) GetRank(key string) (int64, error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
-	if _, ok := s.dict[key]; !ok {
-		return 0, errors.New("not find key " + key)
-	}
-	var x *node
-	var rank int64 = 0
-	score := *s.dict[key]
-	x = s.skipList.header
-	for i := s.skipList.level - 1; i >= 0; i-- {
-		for x.level[i].forward != nil && (x.level[i].forward.score < score || (x.level[i].forward.score == score && x.level[i].forward.key <= key)) {
-			rank += x.level[i].span
-			x = x.level[i].forward
-		}
-		if x.key == key {
-			return rank, nil
-		}
-	}
-	return 0, nil
+	return s.rank(key)
 }
 
 // 按降序排名
 func (s *Set) GetRankDESC(key string) (int64, error) {
-	rank, err := s.GetRank(key)
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	rank, err := s.rank(key)
 	if err != nil {
 		return 0, err
 	}
-	return s.GetLenth() - rank + 1, nil
+	return s.skipList.Len() - rank + 1, nil
 }
 
-func (s *Set) Del(key string) error {
-	s.lock.Lock()
-	defer s.lock.Unlock()
-	if _, ok := s.dict[key]; !ok {
-		return errors.New("not find key " + key)
+// rank returns the 1-based rank of key in ascending order, the caller must
+// hold the lock.
+func (s *Set) rank(key string) (int64, error) {
+	score, ok := s.dict[key]
+	if !ok {
+		return 0, notFound(key)
 	}
-	score := *s.dict[key]
-	var update [DefaultMaxLevel]*node
-	var x *node
-	x = s.skipList.header
-	var i int
-	for i = s.skipList.level - 1; i >= 0; i-- {
-		for x.level[i].forward != nil && (x.level[i].forward.score < score || (x.level[i].forward.score == score && x.level[i].forward.key < key)) {
-			x = x.level[i].forward
-		}
-		update[i] = x
+	rank := s.skipList.Rank(member{score, key})
+	if rank == 0 {
+		// The dict and the skip list disagree, never report rank 0.
+		return 0, fmt.Errorf("skiplist: key %s has no rank", key)
 	}
-	x = x.level[0].forward
-	if x != nil && score == x.score && key == x.key {
-		for i = 0; i < s.skipList.level; i++ {
-			if update[i].level[i].forward == x {
-				update[i].level[i].span += x.level[i].span - 1
-				update[i].level[i].forward = x.level[i].forward
-			} else {
-				update[i].level[i].span--
-			}
-		}
-		if x.level[0].forward != nil {
-			x.level[0].forward.backward = x.backward
-		} else {
-			s.skipList.tail = x.backward
-		}
-		for s.skipList.level > 1 && s.skipList.header.level[s.skipList.level-1].forward == nil {
-			s.skipList.level--
-		}
-		s.skipList.length--
+	return rank, nil
+}
+
+// Del removes key, or returns an error matching ErrNotFound.
+func (s *Set) Del(key string) (err error) {
+	if err := s.lockWrite(); err != nil {
+		return err
 	}
+	defer s.unlock(&err)
+	if _, ok := s.dict[key]; !ok {
+		return notFound(key)
+	}
+	s.remove(key)
+	return nil
+}
+
+// remove deletes an existing key, the caller must hold the write lock.
+func (s *Set) remove(key string) {
+	s.skipList.Delete(member{s.dict[key], key})
 	delete(s.dict, key)
+	if s.log != nil {
+		s.log.del(key)
+	}
+}
+
+// lockWrite takes the write lock for a write of s. If the log of s has
+// failed, it returns an error matching ErrLogFailed without taking the lock:
+// s refuses writes then, so that none is acknowledged without being logged.
+func (s *Set) lockWrite() error {
+	s.lock.Lock()
+	if s.log != nil && s.log.err != nil {
+		err := s.log.failed()
+		s.lock.Unlock()
+		return err
+	}
 	return nil
 }
 
+// unlock commits the log records of a write, then releases the write lock.
+// If the log fails, its error is stored in *err unless err is nil or *err
+// holds an error already.
+func (s *Set) unlock(err *error) {
+	if s.log != nil {
+		if cerr := s.log.commit(); cerr != nil && err != nil && *err == nil {
+			*err = cerr
+		}
+	}
+	s.lock.Unlock()
+}
+
+// Set adds key with score, or updates the score of key if it exists.
+// A NaN score is rejected: Set returns nil and leaves s unchanged, use Add
+// to get ErrNaN instead. So is any score once the log of s has failed, see
+// OpenLog.
 func (s *Set) Set(key string, score float64) *node {
-	s.Del(key)
-	node := s.insert(key, score)
-	return node
+	if math.IsNaN(score) || s.lockWrite() != nil {
+		return nil
+	}
+	defer s.unlock(nil)
+	if cur, ok := s.dict[key]; ok {
+		return s.updateScore(key, cur, score)
+	}
+	return s.insert(key, score)
+}
+
+// updateScore changes the score of key from cur to score, the caller must
+// hold the write lock.
+func (s *Set) updateScore(key string, cur, score float64) *node {
+	s.dict[key] = score
+	if s.log != nil {
+		s.log.set(key, score)
+	}
+	return s.skipList.updateKey(member{cur, key}, member{score, key})
 }
 
 // 获取top n个数 按升序获取
@@ -191,11 +239,8 @@ func (s *Set) GetTopN(n int64) []Node {
 	defer s.lock.RUnlock()
 	var nodes []Node
 	var q int64 = 0
-	for x := s.skipList.header.level[0].forward; x != nil && q < n; x = x.level[0].forward {
-		var node Node
-		node.Key = x.key
-		node.Score = x.score
-		nodes = append(nodes, node)
+	for x := s.skipList.Front(); x != nil && q < n; x = x.Next() {
+		nodes = append(nodes, toNode(x))
 		q++
 	}
 	return nodes
@@ -207,93 +252,72 @@ func (s *Set) GetTopNDESC(n int64) []Node {
 	defer s.lock.RUnlock()
 	var nodes []Node
 	var q int64 = 0
-	for x := s.skipList.tail; x != nil && q < n; x = x.backward {
-		var node Node
-		node.Key = x.key
-		node.Score = x.score
-		nodes = append(nodes, node)
+	for x := s.skipList.Back(); x != nil && q < n; x = x.Prev() {
+		nodes = append(nodes, toNode(x))
 		q++
 	}
 	return nodes
 }
 
+// insert adds a new key, the caller must hold the write lock.
 func (s *Set) insert(key string, score float64) *node {
-	s.lock.Lock()
-	defer s.lock.Unlock()
-	var update [DefaultMaxLevel]*node
-	var x *node
-	var rank [DefaultMaxLevel]int64
-	var i, level int
-
-	x = s.skipList.header
-
-	for i = s.skipList.level - 1; i >= 0; i-- {
-		rank[i] = 0
-		if i != s.skipList.level-1 {
-			rank[i] = rank[i+1]
-		}
-		for x.level[i].forward != nil && (x.level[i].forward.score < score || (x.level[i].forward.score == score && x.level[i].forward.key < key)) {
-			rank[i] += x.level[i].span
-			x = x.level[i].forward
-		}
-		update[i] = x
-	}
-	level = s.randomLevel()
-	if level > s.skipList.level {
-		for i = s.skipList.level; i < level; i++ {
-			rank[i] = 0
-			update[i] = s.skipList.header
-			update[i].level[i].span = s.skipList.length
-		}
-		s.skipList.level = level
-	}
-	x = s.createNode(key, score)
-	for i = 0; i < level; i++ {
-		x.level[i].forward = update[i].level[i].forward
-		update[i].level[i].forward = x
-		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
-		update[i].level[i].span = (rank[0] - rank[i]) + 1
-	}
-	for i = level; i < s.skipList.level; i++ {
-		update[i].level[i].span++
+	s.dict[key] = score
+	if s.log != nil {
+		s.log.set(key, score)
 	}
-	if update[0] != s.skipList.header {
-		x.backward = update[0]
+	if s.added != nil {
+		close(s.added)
+		s.added = nil
 	}
-	if x.level[0].forward != nil {
-		x.level[0].forward.backward = x
-	} else {
-		s.skipList.tail = x
-	}
-	s.skipList.length++
-	s.dict[key] = &x.score
-	return x
+	return s.skipList.Put(member{score, key}, struct{}{})
 }
 
-func (s *Set) GetLenth() int64 {
+// GetLength returns the number of elements.
+func (s *Set) GetLength() int64 {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
-	return s.skipList.length
+	return s.skipList.Len()
 }
 
-func (s *Set) GetLevel() int {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
-	return s.skipList.level
+// GetLenth returns the number of elements.
+//
+// Deprecated: use GetLength.
+func (s *Set) GetLenth() int64 {
+	return s.GetLength()
 }
 
-func (s *Set) randomLevel() int {
-	rand.Seed(time.Now().UnixNano())
-	p := 0.25
-	level := 1
-	for (float64(rand.Int63() & 0xFFFF)) < (p * 0xFFFF) {
-		level++
+// Len returns the number of elements.
+func (s *Set) Len() int {
+	return int(s.GetLength())
+}
+
+// IsEmpty reports whether the Set has no elements.
+func (s *Set) IsEmpty() bool {
+	return s.GetLength() == 0
+}
+
+// Clear removes all elements.
+// It does nothing once the log of s has failed, see OpenLog.
+func (s *Set) Clear() {
+	if s.lockWrite() != nil {
+		return
 	}
-	if level < 32 {
-		return level
+	defer s.unlock(nil)
+	if s.log != nil {
+		for key := range s.dict {
+			s.log.del(key)
+		}
 	}
-	return 32
+	s.dict = make(map[string]float64)
+	s.skipList.Clear()
 }
+
+func (s *Set) GetLevel() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.skipList.Level()
+}
+
 func (s *Set) HasKey(key string) bool {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
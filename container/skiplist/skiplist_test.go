@@ -0,0 +1,600 @@
+package skiplist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/funbytes/modern-go/container"
+)
+
+// newTestSet returns a Set filled with n random elements and the same
+// elements sorted by score then key.
+func newTestSet(n int, seed int64) (*Set, []Node) {
+	r := rand.New(rand.NewSource(seed))
+	s := NewSet()
+	for i := 0; i < n; i++ {
+		s.Set(fmt.Sprintf("k%03d", i), float64(r.Intn(n/2+1)))
+	}
+	return s, dump(s)
+}
+
+// dump returns all elements of s walking the bottom level.
+func dump(s *Set) []Node {
+	var nodes []Node
+	for x := s.skipList.Front(); x != nil; x = x.Next() {
+		nodes = append(nodes, toNode(x))
+	}
+	return nodes
+}
+
+func reversed(nodes []Node) []Node {
+	out := make([]Node, len(nodes))
+	for i, n := range nodes {
+		out[len(nodes)-1-i] = n
+	}
+	return out
+}
+
+// inRange reports whether n is in range r.
+func inRange(r rangeSpec, n Node) bool {
+	m := member{n.Score, n.Key}
+	return r.gteMin(m) && r.lteMax(m)
+}
+
+func TestSetOrder(t *testing.T) {
+	s, nodes := newTestSet(200, 1)
+	if int64(len(nodes)) != s.GetLength() {
+		t.Fatalf("walked %d elements, GetLength = %d", len(nodes), s.GetLength())
+	}
+	ok := sort.SliceIsSorted(nodes, func(i, j int) bool {
+		return nodes[i].Score < nodes[j].Score || (nodes[i].Score == nodes[j].Score && nodes[i].Key < nodes[j].Key)
+	})
+	if !ok {
+		t.Fatal("elements are not sorted by score then key")
+	}
+	for i, n := range nodes {
+		if rank, _ := s.GetRank(n.Key); rank != int64(i+1) {
+			t.Fatalf("GetRank(%s) = %d, want %d", n.Key, rank, i+1)
+		}
+	}
+}
+
+func TestRangeByRank(t *testing.T) {
+	s, nodes := newTestSet(100, 2)
+	cases := []struct {
+		start, stop int64
+		want        []Node
+	}{
+		{1, -1, nodes},
+		{1, 10, nodes[:10]},
+		{-10, -1, nodes[90:]},
+		{50, 49, nil},
+		{95, 200, nodes[94:]},
+		{101, 200, nil},
+	}
+	for _, c := range cases {
+		if got := s.RangeByRank(c.start, c.stop, false); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("RangeByRank(%d, %d, false) = %v, want %v", c.start, c.stop, got, c.want)
+		}
+	}
+	if got, want := s.RangeByRank(1, 5, true), reversed(nodes)[:5]; !reflect.DeepEqual(got, want) {
+		t.Errorf("RangeByRank(1, 5, true) = %v, want %v", got, want)
+	}
+}
+
+func TestRangeByScore(t *testing.T) {
+	s, nodes := newTestSet(100, 3)
+	ranges := []ScoreRange{
+		{Min: 10, Max: 20},
+		{Min: 10, Max: 20, MinExclusive: true},
+		{Min: 10, Max: 20, MaxExclusive: true},
+		{Min: 10, Max: 10},
+		{Min: 10, Max: 10, MinExclusive: true},
+		{Min: -100, Max: 1000},
+		{Min: 30, Max: 20},
+		{Min: 1000, Max: 2000},
+	}
+	for _, r := range ranges {
+		var want []Node
+		for _, n := range nodes {
+			if inRange(r, n) {
+				want = append(want, n)
+			}
+		}
+		if got := s.RangeByScore(r, 0, -1, false); !reflect.DeepEqual(got, want) {
+			t.Errorf("RangeByScore(%+v) = %v, want %v", r, got, want)
+		}
+		if got := s.CountInScore(r); got != int64(len(want)) {
+			t.Errorf("CountInScore(%+v) = %d, want %d", r, got, len(want))
+		}
+		if len(want) > 3 {
+			if got := s.RangeByScore(r, 2, 1, false); !reflect.DeepEqual(got, want[2:3]) {
+				t.Errorf("RangeByScore(%+v, 2, 1) = %v, want %v", r, got, want[2:3])
+			}
+			if got := s.RangeByScore(r, 1, 2, true); !reflect.DeepEqual(got, reversed(want)[1:3]) {
+				t.Errorf("RangeByScore(%+v, 1, 2, desc) = %v, want %v", r, got, reversed(want)[1:3])
+			}
+		}
+		if got := s.RangeByScore(r, int64(len(want)), -1, false); got != nil {
+			t.Errorf("RangeByScore(%+v) past the end = %v, want nil", r, got)
+		}
+	}
+}
+
+func TestRemoveRange(t *testing.T) {
+	s, nodes := newTestSet(100, 4)
+	if n := s.RemoveRangeByRank(11, 20); n != 10 {
+		t.Fatalf("RemoveRangeByRank removed %d, want 10", n)
+	}
+	nodes = append(nodes[:10:10], nodes[20:]...)
+	if got := dump(s); !reflect.DeepEqual(got, nodes) {
+		t.Fatalf("after RemoveRangeByRank = %v, want %v", got, nodes)
+	}
+	r := ScoreRange{Min: 20, Max: 30, MaxExclusive: true}
+	var want []Node
+	for _, n := range nodes {
+		if !(inRange(r, n)) {
+			want = append(want, n)
+		}
+	}
+	if n := s.RemoveRangeByScore(r); n != int64(len(nodes)-len(want)) {
+		t.Fatalf("RemoveRangeByScore removed %d, want %d", n, len(nodes)-len(want))
+	}
+	if got := dump(s); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after RemoveRangeByScore = %v, want %v", got, want)
+	}
+	for i, n := range want {
+		if rank, _ := s.GetRank(n.Key); rank != int64(i+1) {
+			t.Fatalf("GetRank(%s) = %d, want %d", n.Key, rank, i+1)
+		}
+	}
+	if len(s.dict) != len(want) || s.GetLength() != int64(len(want)) {
+		t.Fatal("dictionary is out of sync with the list")
+	}
+	if n := s.RemoveRangeByRank(1, -1); n != int64(len(want)) || s.GetLength() != 0 {
+		t.Fatalf("RemoveRangeByRank(1, -1) removed %d, %d left", n, s.GetLength())
+	}
+}
+
+func TestAddFlags(t *testing.T) {
+	s := NewSet()
+	s.Set("a", 10)
+	cases := []struct {
+		key   string
+		score float64
+		flags AddFlag
+		ret   bool
+		want  float64
+	}{
+		{"a", 20, AddNX, false, 10},
+		{"b", 5, AddNX, true, 5},
+		{"c", 5, AddXX, false, 0},
+		{"a", 20, AddXX, false, 20},
+		{"a", 30, AddXX | AddCH, true, 30},
+		{"a", 25, AddGT | AddCH, false, 30},
+		{"a", 35, AddGT | AddCH, true, 35},
+		{"a", 40, AddLT | AddCH, false, 35},
+		{"a", 1, AddLT | AddCH, true, 1},
+		{"a", 1, AddCH, false, 1},
+		{"d", 7, AddGT, true, 7},
+	}
+	for _, c := range cases {
+		ret, err := s.Add(c.key, c.score, c.flags)
+		if err != nil || ret != c.ret {
+			t.Errorf("Add(%s, %v, %b) = (%v, %v), want %v", c.key, c.score, c.flags, ret, err, c.ret)
+		}
+		if score, _ := s.GetScore(c.key); score != c.want {
+			t.Errorf("after Add(%s, %v, %b) score = %v, want %v", c.key, c.score, c.flags, score, c.want)
+		}
+	}
+	for _, flags := range []AddFlag{AddNX | AddXX, AddNX | AddGT, AddGT | AddLT} {
+		if _, err := s.Add("a", 1, flags); err != ErrIncompatibleFlags {
+			t.Errorf("Add with flags %b = %v, want ErrIncompatibleFlags", flags, err)
+		}
+	}
+	if got, want := dump(s), []Node{{"a", 1}, {"b", 5}, {"d", 7}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("elements = %v, want %v", got, want)
+	}
+}
+
+func TestIncrBy(t *testing.T) {
+	s, _ := newTestSet(50, 5)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("k%03d", i%60)
+		before, _ := s.GetScore(key)
+		got, err := s.IncrBy(key, float64(i%7-3))
+		if err != nil || got != before+float64(i%7-3) {
+			t.Fatalf("IncrBy(%s) = (%v, %v), want %v", key, got, err, before+float64(i%7-3))
+		}
+	}
+	nodes := dump(s)
+	for i, n := range nodes {
+		if score, _ := s.GetScore(n.Key); score != n.Score {
+			t.Fatalf("GetScore(%s) = %v, list has %v", n.Key, score, n.Score)
+		}
+		if rank, _ := s.GetRank(n.Key); rank != int64(i+1) {
+			t.Fatalf("GetRank(%s) = %d, want %d", n.Key, rank, i+1)
+		}
+	}
+}
+
+func TestNaNScore(t *testing.T) {
+	s := NewSet()
+	s.Set("a", 1)
+	s.Set("b", 2)
+	nan := math.NaN()
+	if x := s.Set("a", nan); x != nil {
+		t.Errorf("Set(a, NaN) = %v, want nil", x)
+	}
+	if x := s.Set("c", nan); x != nil || s.HasKey("c") {
+		t.Errorf("Set(c, NaN) added c")
+	}
+	if _, err := s.Add("a", nan, 0); !errors.Is(err, ErrNaN) {
+		t.Errorf("Add(a, NaN) = %v, want ErrNaN", err)
+	}
+	if _, err := s.IncrBy("inf", math.Inf(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.IncrBy("inf", math.Inf(-1)); !errors.Is(err, ErrNaN) {
+		t.Errorf("IncrBy(+Inf, -Inf) = %v, want ErrNaN", err)
+	}
+	if got, want := dump(s), []Node{{"a", 1}, {"b", 2}, {"inf", math.Inf(1)}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("elements = %v, want %v", got, want)
+	}
+	for i, n := range dump(s) {
+		if rank, err := s.GetRank(n.Key); err != nil || rank != int64(i+1) {
+			t.Errorf("GetRank(%s) = (%d, %v), want %d", n.Key, rank, err, i+1)
+		}
+	}
+}
+
+func TestSetIsAtomic(t *testing.T) {
+	s := NewSet()
+	s.Set("key", 0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 2000; i++ {
+			s.Set("key", float64(i%10))
+			s.IncrBy("key", 1)
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			if !s.HasKey("key") {
+				t.Fatal("reader observed the key missing during Set")
+			}
+		}
+	}
+}
+
+func TestLexRange(t *testing.T) {
+	s := NewSet()
+	words := []string{"a", "ab", "abc", "abd", "b", "ba", "c", "\xff", "\xff\xff"}
+	for _, w := range words {
+		s.Set(w, 0)
+	}
+	parse := func(min, max string) LexRange {
+		r, err := ParseLexRange(min, max)
+		if err != nil {
+			t.Fatalf("ParseLexRange(%q, %q): %v", min, max, err)
+		}
+		return r
+	}
+	cases := []struct {
+		r    LexRange
+		want []string
+	}{
+		{parse("-", "+"), words},
+		{parse("[ab", "[b"), []string{"ab", "abc", "abd", "b"}},
+		{parse("(ab", "(b"), []string{"abc", "abd"}},
+		{parse("[b", "[a"), nil},
+		{parse("+", "+"), nil},
+		{parse("-", "-"), nil},
+		{PrefixRange("ab"), []string{"ab", "abc", "abd"}},
+		{PrefixRange("\xff"), []string{"\xff", "\xff\xff"}},
+		{PrefixRange(""), words},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, n := range s.RangeByLex(c.r, 0, -1, false) {
+			got = append(got, n.Key)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("RangeByLex(%+v) = %q, want %q", c.r, got, c.want)
+		}
+		if n := s.CountByLex(c.r); n != int64(len(c.want)) {
+			t.Errorf("CountByLex(%+v) = %d, want %d", c.r, n, len(c.want))
+		}
+	}
+	if got := s.RangeByLex(PrefixRange("a"), 1, 2, true); !reflect.DeepEqual(got, []Node{{"abc", 0}, {"ab", 0}}) {
+		t.Errorf("RangeByLex(prefix a, 1, 2, desc) = %v", got)
+	}
+	for _, item := range []string{"a", ""} {
+		if _, err := ParseLexRange(item, "+"); err == nil {
+			t.Errorf("ParseLexRange(%q) should fail", item)
+		}
+	}
+	if n := s.RemoveRangeByLex(PrefixRange("ab")); n != 3 {
+		t.Errorf("RemoveRangeByLex removed %d, want 3", n)
+	}
+	if got := s.RangeByRank(1, -1, false); len(got) != len(words)-3 || s.HasKey("abc") {
+		t.Errorf("after RemoveRangeByLex = %v", got)
+	}
+}
+
+func newSetOf(elements map[string]float64) *Set {
+	s := NewSet()
+	for key, score := range elements {
+		s.Set(key, score)
+	}
+	return s
+}
+
+func TestSetOperations(t *testing.T) {
+	a := newSetOf(map[string]float64{"x": 1, "y": 2, "z": 3})
+	b := newSetOf(map[string]float64{"y": 10, "z": 20, "w": 30})
+	c := newSetOf(map[string]float64{"z": 100})
+	cases := []struct {
+		name string
+		got  func() (*Set, error)
+		want []Node
+	}{
+		{"union sum", func() (*Set, error) { return Union([]*Set{a, b}, nil, AggregateSum) },
+			[]Node{{"x", 1}, {"y", 12}, {"z", 23}, {"w", 30}}},
+		{"union weighted max", func() (*Set, error) { return Union([]*Set{a, b}, []float64{10, 1}, AggregateMax) },
+			[]Node{{"x", 10}, {"y", 20}, {"w", 30}, {"z", 30}}},
+		{"intersect min", func() (*Set, error) { return Intersect([]*Set{a, b}, nil, AggregateMin) },
+			[]Node{{"y", 2}, {"z", 3}}},
+		{"intersect three", func() (*Set, error) { return Intersect([]*Set{a, b, c}, []float64{1, 1, -1}, AggregateSum) },
+			[]Node{{"z", -77}}},
+		{"intersect same set twice", func() (*Set, error) { return Intersect([]*Set{a, a}, nil, AggregateSum) },
+			[]Node{{"x", 2}, {"y", 4}, {"z", 6}}},
+		{"diff", func() (*Set, error) { return Diff([]*Set{a, b}), nil },
+			[]Node{{"x", 1}}},
+		{"diff three", func() (*Set, error) { return Diff([]*Set{b, a, c}), nil },
+			[]Node{{"w", 30}}},
+		{"union with nil", func() (*Set, error) { return Union([]*Set{nil, a, nil}, []float64{5, 2, 5}, AggregateSum) },
+			[]Node{{"x", 2}, {"y", 4}, {"z", 6}}},
+		{"intersect with nil", func() (*Set, error) { return Intersect([]*Set{a, nil}, nil, AggregateSum) },
+			nil},
+		{"diff with nil", func() (*Set, error) { return Diff([]*Set{a, nil, b}), nil },
+			[]Node{{"x", 1}}},
+		{"diff of nil", func() (*Set, error) { return Diff([]*Set{nil, a}), nil },
+			nil},
+	}
+	for _, c := range cases {
+		s, err := c.got()
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if got := dump(s); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s = %v, want %v", c.name, got, c.want)
+		}
+	}
+	if _, err := Union([]*Set{a, b}, []float64{1}, AggregateSum); err != ErrWeights {
+		t.Errorf("Union with one weight for two sets = %v, want ErrWeights", err)
+	}
+}
+
+func TestSetOperationsConcurrent(t *testing.T) {
+	a, _ := newTestSet(100, 6)
+	b, _ := newTestSet(100, 7)
+	done := make(chan struct{})
+	for g := 0; g < 4; g++ {
+		go func(g int) {
+			defer func() { done <- struct{}{} }()
+			for i := 0; i < 50; i++ {
+				if g%2 == 0 {
+					Union([]*Set{a, b}, nil, AggregateSum)
+					Intersect([]*Set{b, a}, nil, AggregateMax)
+				} else {
+					a.IncrBy(fmt.Sprintf("k%03d", i), 1)
+					b.Del(fmt.Sprintf("k%03d", i))
+				}
+			}
+		}(g)
+	}
+	for g := 0; g < 4; g++ {
+		<-done
+	}
+}
+
+func TestPop(t *testing.T) {
+	s := newSetOf(map[string]float64{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5})
+	if got, want := s.PopMin(2), []Node{{"a", 1}, {"b", 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PopMin(2) = %v, want %v", got, want)
+	}
+	if got, want := s.PopMax(2), []Node{{"e", 5}, {"d", 4}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PopMax(2) = %v, want %v", got, want)
+	}
+	if got, want := s.PopMax(10), []Node{{"c", 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PopMax(10) = %v, want %v", got, want)
+	}
+	if got := s.PopMin(1); got != nil || s.GetLength() != 0 || len(s.dict) != 0 {
+		t.Errorf("PopMin on empty set = %v, %d left", got, s.GetLength())
+	}
+}
+
+func TestBPopMin(t *testing.T) {
+	s := NewSet()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.BPopMin(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("BPopMin on empty set = %v, want DeadlineExceeded", err)
+	}
+
+	// Consumers race for scheduled items, every item is taken exactly once.
+	const items, consumers = 200, 4
+	results := make(chan Node, items)
+	for c := 0; c < consumers; c++ {
+		go func() {
+			for {
+				n, err := s.BPopMin(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				results <- n
+				if n.Score < 0 {
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < items; i++ {
+		s.Set(fmt.Sprintf("job%03d", i), float64(i))
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < items; i++ {
+		n := <-results
+		if seen[n.Key] {
+			t.Fatalf("%s popped twice", n.Key)
+		}
+		seen[n.Key] = true
+	}
+	for c := 0; c < consumers; c++ {
+		s.Set(fmt.Sprintf("stop%d", c), -1)
+	}
+	for c := 0; c < consumers; c++ {
+		<-results
+	}
+}
+
+func TestScan(t *testing.T) {
+	s, nodes := newTestSet(100, 9)
+	for _, desc := range []bool{false, true} {
+		want := nodes
+		if desc {
+			want = reversed(nodes)
+		}
+		var got []Node
+		var cursor *Cursor
+		for page := 0; ; page++ {
+			var nodes []Node
+			nodes, cursor = s.Scan(cursor, 7, desc)
+			got = append(got, nodes...)
+			if cursor == nil {
+				break
+			}
+			if page > 100 {
+				t.Fatal("scan does not end")
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Scan(desc=%v) = %v, want %v", desc, got, want)
+		}
+	}
+
+	r := ScoreRange{Min: 10, Max: 30, MaxExclusive: true}
+	var want []Node
+	for _, n := range nodes {
+		if inRange(r, n) {
+			want = append(want, n)
+		}
+	}
+	page, cursor := s.ScanByScore(r, nil, 5, true)
+	if !reflect.DeepEqual(page, reversed(want)[:5]) || cursor == nil {
+		t.Fatalf("ScanByScore first page = %v, %v", page, cursor)
+	}
+	page, _ = s.ScanByScore(r, cursor, 5, true)
+	if !reflect.DeepEqual(page, reversed(want)[5:10]) {
+		t.Fatalf("ScanByScore second page = %v, want %v", page, reversed(want)[5:10])
+	}
+}
+
+func TestScanConcurrentChanges(t *testing.T) {
+	s := NewSet()
+	for i := 0; i < 100; i++ {
+		s.Set(fmt.Sprintf("stable%03d", i), float64(i*2))
+	}
+	seen := make(map[string]int)
+	var cursor *Cursor
+	for page := 0; ; page++ {
+		var nodes []Node
+		nodes, cursor = s.Scan(cursor, 10, false)
+		for _, n := range nodes {
+			seen[n.Key]++
+		}
+		if cursor == nil {
+			break
+		}
+		// Elements added before and after the cursor, and the element at
+		// the cursor removed, must not disturb the pages.
+		s.Set(fmt.Sprintf("early%03d", page), -1)
+		s.Set(fmt.Sprintf("late%03d", page), 1000)
+		s.Del(cursor.Key)
+		s.Set(cursor.Key, cursor.Score)
+	}
+	for i := 0; i < 100; i++ {
+		if key := fmt.Sprintf("stable%03d", i); seen[key] != 1 {
+			t.Errorf("%s seen %d times", key, seen[key])
+		}
+	}
+	for key, n := range seen {
+		if key[:5] == "early" || n != 1 {
+			t.Errorf("%s seen %d times", key, n)
+		}
+	}
+}
+
+func TestLookupErrors(t *testing.T) {
+	s := newSetOf(map[string]float64{"": 1, "a": 2, "b": 3})
+	for name, err := range map[string]error{
+		"GetScore":    func() error { _, err := s.GetScore("x"); return err }(),
+		"GetRank":     func() error { _, err := s.GetRank("x"); return err }(),
+		"GetRankDESC": func() error { _, err := s.GetRankDESC("x"); return err }(),
+		"Del":         s.Del("x"),
+	} {
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s of a missing key = %v, want ErrNotFound", name, err)
+		}
+	}
+	if n, ok := s.ElementByRankASC(1); !ok || n != (Node{Key: "", Score: 1}) {
+		t.Errorf("ElementByRankASC(1) = %v, %v, want the empty key", n, ok)
+	}
+	if n, ok := s.ElementByRankDESC(1); !ok || n.Key != "b" {
+		t.Errorf("ElementByRankDESC(1) = %v, %v, want b", n, ok)
+	}
+	for _, rank := range []int64{-1, 0, 4} {
+		if n, ok := s.ElementByRankASC(rank); ok {
+			t.Errorf("ElementByRankASC(%d) = %v, want not found", rank, n)
+		}
+		if n, ok := s.ElementByRankDESC(rank); ok {
+			t.Errorf("ElementByRankDESC(%d) = %v, want not found", rank, n)
+		}
+	}
+	if rank, err := s.GetRankDESC("a"); err != nil || rank != 2 {
+		t.Errorf("GetRankDESC(a) = %d, %v, want 2", rank, err)
+	}
+}
+
+func TestSetContainer(t *testing.T) {
+	var s container.Container = NewSet(WithSafe(false))
+	if !s.IsEmpty() || NewSet(WithSafe(false)).lock.IsSafe() || !NewSet().lock.IsSafe() {
+		t.Fatal("WithSafe is not applied")
+	}
+	set, nodes := newTestSet(50, 5)
+	if set.Len() != len(nodes) {
+		t.Fatalf("Len = %d, want %d", set.Len(), len(nodes))
+	}
+	set.Clear()
+	if !set.IsEmpty() || set.HasKey(nodes[0].Key) || len(dump(set)) != 0 {
+		t.Fatal("Clear left elements")
+	}
+	set.Set("a", 1)
+	if rank, _ := set.GetRank("a"); rank != 1 {
+		t.Fatalf("GetRank after Clear = %d, want 1", rank)
+	}
+}
@@ -0,0 +1,96 @@
+package stack
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+type lfNode[T any] struct {
+	value T
+	next  *lfNode[T]
+}
+
+// LockFreeStack is an unbounded lock-free LIFO stack, after Treiber: Push
+// and Pop are a CAS on the top pointer, so it suits contention-heavy use
+// better than SliceStack, at the cost of one allocation per Push. The
+// garbage collector keeps a node alive while any goroutine still reads it,
+// so it is free of ABA.
+type LockFreeStack[T any] struct {
+	// length is accessed atomically, and first so that it is 64-bit aligned
+	// on 32-bit platforms.
+	length int64
+	top    unsafe.Pointer // *lfNode[T]
+}
+
+// NewLockFreeStack creates and returns an empty LockFreeStack.
+func NewLockFreeStack[T any]() *LockFreeStack[T] {
+	return new(LockFreeStack[T])
+}
+
+// Length returns the number of elements in the stack, which is only a hint
+// while other goroutines push or pop.
+func (s *LockFreeStack[T]) Length() int {
+	if n := atomic.LoadInt64(&s.length); n > 0 {
+		return int(n)
+	}
+	return 0
+}
+
+// Len returns the number of elements in the stack, like Length.
+func (s *LockFreeStack[T]) Len() int {
+	return s.Length()
+}
+
+// IsEmpty reports whether the stack has no elements.
+func (s *LockFreeStack[T]) IsEmpty() bool {
+	return atomic.LoadPointer(&s.top) == nil
+}
+
+// Clear removes all elements.
+func (s *LockFreeStack[T]) Clear() {
+	var n int64
+	for x := (*lfNode[T])(atomic.SwapPointer(&s.top, nil)); x != nil; x = x.next {
+		n++
+	}
+	atomic.AddInt64(&s.length, -n)
+}
+
+// Push pushes v on top of the stack.
+func (s *LockFreeStack[T]) Push(v T) {
+	n := &lfNode[T]{value: v}
+	for {
+		top := atomic.LoadPointer(&s.top)
+		n.next = (*lfNode[T])(top)
+		if atomic.CompareAndSwapPointer(&s.top, top, unsafe.Pointer(n)) {
+			atomic.AddInt64(&s.length, 1)
+			return
+		}
+	}
+}
+
+// Pop removes and returns the top element, and reports false if the stack
+// is empty.
+func (s *LockFreeStack[T]) Pop() (T, bool) {
+	for {
+		top := (*lfNode[T])(atomic.LoadPointer(&s.top))
+		if top == nil {
+			var zero T
+			return zero, false
+		}
+		if atomic.CompareAndSwapPointer(&s.top, unsafe.Pointer(top), unsafe.Pointer(top.next)) {
+			atomic.AddInt64(&s.length, -1)
+			return top.value, true
+		}
+	}
+}
+
+// Peek returns the top element without removing it, and reports false if
+// the stack is empty.
+func (s *LockFreeStack[T]) Peek() (T, bool) {
+	top := (*lfNode[T])(atomic.LoadPointer(&s.top))
+	if top == nil {
+		var zero T
+		return zero, false
+	}
+	return top.value, true
+}
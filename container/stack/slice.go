@@ -0,0 +1,173 @@
+package stack
+
+import "github.com/funbytes/modern-go/internal/rwmutex"
+
+// OverflowPolicy tells what Push does on a full bounded SliceStack.
+type OverflowPolicy int
+
+const (
+	// OverflowReject makes Push fail, leaving the stack unchanged.
+	OverflowReject OverflowPolicy = iota
+	// OverflowDropOldest makes Push discard the bottom element to make room.
+	OverflowDropOldest
+)
+
+// SliceStack is a typed LIFO stack backed by a ring buffer, so that pushes
+// do not allocate once it has grown.
+type SliceStack[T any] struct {
+	lock     *rwmutex.RWMutex
+	buf      []T
+	bottom   int // index of the bottom element in buf
+	length   int
+	capacity int // 0 for an unbounded stack
+	overflow OverflowPolicy
+}
+
+// NewSliceStack creates and returns an empty SliceStack holding at most
+// capacity elements, or any number if capacity is not positive. overflow
+// tells what Push does when the stack is full. The stack is concurrent-safe
+// unless safe is given as false.
+func NewSliceStack[T any](capacity int, overflow OverflowPolicy, safe ...bool) *SliceStack[T] {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &SliceStack[T]{
+		lock:     rwmutex.NewDefaultSafe(safe...),
+		capacity: capacity,
+		overflow: overflow,
+	}
+}
+
+// Length returns the number of elements in the stack.
+func (s *SliceStack[T]) Length() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.length
+}
+
+// Len returns the number of elements in the stack.
+func (s *SliceStack[T]) Len() int {
+	return s.Length()
+}
+
+// IsEmpty reports whether the stack has no elements.
+func (s *SliceStack[T]) IsEmpty() bool {
+	return s.Length() == 0
+}
+
+// Cap returns the capacity of the stack, or 0 if it is unbounded.
+func (s *SliceStack[T]) Cap() int {
+	return s.capacity
+}
+
+// Push pushes v on top of the stack. On a full stack it reports false with
+// OverflowReject, and discards the bottom element with OverflowDropOldest.
+func (s *SliceStack[T]) Push(v T) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.capacity > 0 && s.length == s.capacity {
+		if s.overflow == OverflowReject {
+			return false
+		}
+		var zero T
+		s.buf[s.bottom] = zero
+		s.bottom = (s.bottom + 1) % len(s.buf)
+		s.length--
+	}
+	if s.length == len(s.buf) {
+		s.grow()
+	}
+	s.buf[s.index(s.length)] = v
+	s.length++
+	return true
+}
+
+// Pop removes and returns the top element, and reports false if the stack
+// is empty.
+func (s *SliceStack[T]) Pop() (T, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.length == 0 {
+		var zero T
+		return zero, false
+	}
+	return s.pop(), true
+}
+
+// Peek returns the top element without removing it, and reports false if
+// the stack is empty.
+func (s *SliceStack[T]) Peek() (T, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.length == 0 {
+		var zero T
+		return zero, false
+	}
+	return s.buf[s.index(s.length-1)], true
+}
+
+// PopN removes and returns up to n top elements, the top one first.
+func (s *SliceStack[T]) PopN(n int) []T {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if n > s.length {
+		n = s.length
+	}
+	if n <= 0 {
+		return nil
+	}
+	values := make([]T, n)
+	for i := range values {
+		values[i] = s.pop()
+	}
+	return values
+}
+
+// ToSlice returns the elements from bottom to top.
+func (s *SliceStack[T]) ToSlice() []T {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	values := make([]T, s.length)
+	for i := range values {
+		values[i] = s.buf[s.index(i)]
+	}
+	return values
+}
+
+// Clear removes all elements.
+func (s *SliceStack[T]) Clear() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.buf, s.bottom, s.length = nil, 0, 0
+}
+
+// index returns the position in buf of the i-th element from the bottom.
+func (s *SliceStack[T]) index(i int) int {
+	return (s.bottom + i) % len(s.buf)
+}
+
+// pop removes the top element, the caller must hold the write lock and have
+// checked the stack is not empty.
+func (s *SliceStack[T]) pop() T {
+	var zero T
+	i := s.index(s.length - 1)
+	v := s.buf[i]
+	s.buf[i] = zero
+	s.length--
+	return v
+}
+
+// grow doubles the ring buffer, up to the capacity of a bounded stack.
+func (s *SliceStack[T]) grow() {
+	size := 2 * len(s.buf)
+	if size == 0 {
+		size = 8
+	}
+	if s.capacity > 0 && size > s.capacity {
+		size = s.capacity
+	}
+	buf := make([]T, size)
+	n := copy(buf, s.buf[s.bottom:])
+	copy(buf[n:], s.buf[:s.bottom])
+	s.buf, s.bottom = buf, 0
+}
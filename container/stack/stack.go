@@ -1,55 +1,46 @@
 package stack
 
-import (
-	"container/list"
-	"sync"
-)
-
+// Stack is a stack of interface{} values, a thin wrapper of SliceStack kept
+// for compatibility. New code should use SliceStack or LockFreeStack.
 type Stack struct {
-	list *list.List
-	lock *sync.RWMutex
+	stack *SliceStack[interface{}]
 }
 
 // 新建一个栈
-func NewStack() *Stack {
+// The stack is concurrent-safe unless safe is given as false.
+func NewStack(safe ...bool) *Stack {
 	this := new(Stack)
-	this.list = list.New()
-	this.lock = new(sync.RWMutex)
+	this.stack = NewSliceStack[interface{}](0, OverflowReject, safe...)
 	return this
 }
 
 // 返回栈长度
 func (s *Stack) Length() int {
-	s.lock.RLock()
-	l := s.list.Len()
-	s.lock.RUnlock()
-	return l
+	return s.stack.Length()
+}
+
+// Len returns the number of elements.
+func (s *Stack) Len() int {
+	return s.stack.Length()
+}
+
+// IsEmpty reports whether the stack has no elements.
+func (s *Stack) IsEmpty() bool {
+	return s.stack.Length() == 0
 }
 
 // 栈尾部插入元素
 func (s *Stack) Push(v interface{}) {
-	s.lock.Lock()
-	s.list.PushBack(v)
-	s.lock.Unlock()
+	s.stack.Push(v)
 }
 
 // 栈尾部弹出元素
 func (s *Stack) Pop() interface{} {
-	s.lock.Lock()
-	e := s.list.Back()
-	if e != nil {
-		value := e.Value
-		s.list.Remove(e)
-		s.lock.Unlock()
-		return value
-	}
-	s.lock.Unlock()
-	return nil
+	v, _ := s.stack.Pop()
+	return v
 }
 
 // 清空栈
 func (s *Stack) Clear() {
-	s.lock.Lock()
-	s.list = s.list.Init()
-	s.lock.Unlock()
+	s.stack.Clear()
 }
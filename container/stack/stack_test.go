@@ -0,0 +1,203 @@
+package stack
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/funbytes/modern-go/container"
+	"github.com/funbytes/modern-go/mutex"
+)
+
+func TestStack(t *testing.T) {
+	s := NewStack()
+	if s.Pop() != nil {
+		t.Fatal("Pop of an empty Stack is not nil")
+	}
+	s.Push(1)
+	s.Push("two")
+	if s.Length() != 2 || s.Pop() != "two" || s.Pop() != 1 {
+		t.Fatal("Stack is not LIFO")
+	}
+	s.Push(3)
+	s.Clear()
+	if s.Length() != 0 {
+		t.Fatalf("Length after Clear = %d", s.Length())
+	}
+}
+
+func TestSliceStack(t *testing.T) {
+	s := NewSliceStack[int](0, OverflowReject)
+	if _, ok := s.Pop(); ok {
+		t.Fatal("Pop of an empty stack succeeded")
+	}
+	if _, ok := s.Peek(); ok {
+		t.Fatal("Peek of an empty stack succeeded")
+	}
+	for i := 0; i < 20; i++ {
+		s.Push(i)
+	}
+	if v, ok := s.Peek(); !ok || v != 19 || s.Length() != 20 {
+		t.Fatalf("Peek = %d, %v with Length %d", v, ok, s.Length())
+	}
+	if got := s.PopN(3); !reflect.DeepEqual(got, []int{19, 18, 17}) {
+		t.Fatalf("PopN(3) = %v", got)
+	}
+	if v, _ := s.Pop(); v != 16 {
+		t.Fatalf("Pop = %d, want 16", v)
+	}
+	if got := s.ToSlice(); len(got) != 16 || got[0] != 0 || got[15] != 15 {
+		t.Fatalf("ToSlice = %v", got)
+	}
+	if got := s.PopN(100); len(got) != 16 || s.Length() != 0 {
+		t.Fatalf("PopN(100) returned %d elements, %d left", len(got), s.Length())
+	}
+}
+
+func TestSliceStackOverflow(t *testing.T) {
+	s := NewSliceStack[int](3, OverflowReject)
+	for i := 0; i < 3; i++ {
+		s.Push(i)
+	}
+	if s.Push(3) || !reflect.DeepEqual(s.ToSlice(), []int{0, 1, 2}) {
+		t.Fatalf("Push to a full stack succeeded: %v", s.ToSlice())
+	}
+
+	s = NewSliceStack[int](3, OverflowDropOldest)
+	for i := 0; i < 7; i++ {
+		if !s.Push(i) {
+			t.Fatalf("Push(%d) failed with OverflowDropOldest", i)
+		}
+	}
+	if got := s.ToSlice(); !reflect.DeepEqual(got, []int{4, 5, 6}) {
+		t.Fatalf("ToSlice = %v, want [4 5 6]", got)
+	}
+	s.Pop()
+	s.Push(7)
+	s.Push(8)
+	if got := s.PopN(3); !reflect.DeepEqual(got, []int{8, 7, 5}) {
+		t.Fatalf("PopN(3) = %v, want [8 7 5]", got)
+	}
+}
+
+func TestSliceStackPushDoesNotAllocate(t *testing.T) {
+	if mutex.DebugEnabled() {
+		t.Skip("tracked locks allocate")
+	}
+	s := NewSliceStack[int](0, OverflowReject)
+	for i := 0; i < 64; i++ {
+		s.Push(i)
+	}
+	s.PopN(64)
+	allocs := testing.AllocsPerRun(100, func() {
+		s.Push(1)
+		s.Pop()
+	})
+	if allocs != 0 {
+		t.Fatalf("Push and Pop allocate %v times", allocs)
+	}
+}
+
+func TestLockFreeStack(t *testing.T) {
+	s := NewLockFreeStack[int]()
+	if _, ok := s.Pop(); ok {
+		t.Fatal("Pop of an empty stack succeeded")
+	}
+	s.Push(1)
+	s.Push(2)
+	if v, _ := s.Peek(); v != 2 || s.Length() != 2 {
+		t.Fatalf("Peek = %d with Length %d", v, s.Length())
+	}
+
+	const workers, perWorker = 4, 2000
+	var wg sync.WaitGroup
+	popped := make(chan int, workers*perWorker)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				s.Push(100 + w*perWorker + i)
+				if v, ok := s.Pop(); ok {
+					popped <- v
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(popped)
+	seen := map[int]bool{}
+	for v := range popped {
+		if seen[v] {
+			t.Fatalf("value %d popped twice", v)
+		}
+		seen[v] = true
+	}
+	for {
+		v, ok := s.Pop()
+		if !ok {
+			break
+		}
+		if seen[v] {
+			t.Fatalf("value %d popped twice", v)
+		}
+		seen[v] = true
+	}
+	if len(seen) != workers*perWorker+2 || s.Length() != 0 {
+		t.Fatalf("popped %d distinct values, want %d", len(seen), workers*perWorker+2)
+	}
+}
+
+func BenchmarkStackParallel(b *testing.B) {
+	type stack interface {
+		Push(v int)
+		Pop() (int, bool)
+	}
+	for name, s := range map[string]stack{
+		"SliceStack":    sliceStack{NewSliceStack[int](0, OverflowReject)},
+		"LockFreeStack": NewLockFreeStack[int](),
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s.Push(1)
+					s.Pop()
+				}
+			})
+		})
+	}
+}
+
+// sliceStack adapts SliceStack to the Push of LockFreeStack.
+type sliceStack struct{ *SliceStack[int] }
+
+func (s sliceStack) Push(v int) { s.SliceStack.Push(v) }
+
+func TestContainer(t *testing.T) {
+	for name, c := range map[string]container.Container{
+		"Stack":         NewStack(false),
+		"SliceStack":    NewSliceStack[int](0, OverflowReject, false),
+		"LockFreeStack": NewLockFreeStack[int](),
+	} {
+		if !c.IsEmpty() || c.Len() != 0 {
+			t.Errorf("new %s is not empty", name)
+		}
+	}
+	s := NewSliceStack[int](0, OverflowReject, false)
+	if s.lock.IsSafe() || !NewSliceStack[int](0, OverflowReject).lock.IsSafe() {
+		t.Fatal("the safe flag is not applied")
+	}
+	s.Push(1)
+	s.Push(2)
+	if s.Len() != 2 || s.IsEmpty() {
+		t.Fatalf("Len = %d after two pushes", s.Len())
+	}
+	lf := NewLockFreeStack[int]()
+	lf.Push(1)
+	lf.Push(2)
+	lf.Clear()
+	if !lf.IsEmpty() || lf.Len() != 0 {
+		t.Fatalf("Len = %d after Clear", lf.Len())
+	}
+}
@@ -1,7 +1,7 @@
 package cmap
 
 import (
-	"sync"
+	"github.com/funbytes/modern-go/internal/rwmutex"
 )
 
 // A thread safe map.
@@ -18,7 +18,7 @@ type ConcurrentMap []*sharded
 
 type sharded struct {
 	items map[KType]VType
-	sync.RWMutex
+	*rwmutex.RWMutex
 }
 
 // Used by the Iter & IterBuffered functions to wrap two variables together over a channel,
@@ -27,10 +27,11 @@ type Tuple struct {
 	Val VType
 }
 
-func New() ConcurrentMap {
+// The map is concurrent-safe unless safe is given as false.
+func New(safe ...bool) ConcurrentMap {
 	this := make(ConcurrentMap, SHARD_COUNT)
 	for i := 0; i < SHARD_COUNT; i++ {
-		this[i] = &sharded{items: make(map[KType]VType)}
+		this[i] = &sharded{items: make(map[KType]VType), RWMutex: rwmutex.NewDefaultSafe(safe...)}
 	}
 	return this
 }
@@ -149,6 +150,11 @@ func (m ConcurrentMap) Count() int {
 	return count
 }
 
+// Len returns the number of elements, like Count.
+func (m ConcurrentMap) Len() int {
+	return m.Count()
+}
+
 func (m *ConcurrentMap) Has(key KType) bool {
 	shard := m.GetShard(key)
 	shard.RLock()
@@ -174,8 +180,20 @@ func (m ConcurrentMap) GetAndRemove(key KType) (VType, bool) {
 }
 
 // Returns an iterator which could be used in a for range loop.
+// A map which is not concurrent-safe is read before Iter returns, so that
+// the caller may write to it while draining the channel.
 func (m ConcurrentMap) Iter() <-chan Tuple {
 	ch := make(chan Tuple)
+	if !m.isSafe() {
+		tuples := m.tuples()
+		go func() {
+			for _, t := range tuples {
+				ch <- t
+			}
+			close(ch)
+		}()
+		return ch
+	}
 	go func() {
 		for _, shard := range m {
 			shard.RLock()
@@ -190,8 +208,19 @@ func (m ConcurrentMap) Iter() <-chan Tuple {
 }
 
 // Returns a buffered iterator which could be used in a for range loop.
+// A map which is not concurrent-safe is read before IterBuffered returns,
+// like Iter.
 func (m ConcurrentMap) IterBuffered() <-chan Tuple {
 	ch := make(chan Tuple, m.Count())
+	if !m.isSafe() {
+		for _, shard := range m {
+			for key, val := range shard.items {
+				ch <- Tuple{key, val}
+			}
+		}
+		close(ch)
+		return ch
+	}
 	go func() {
 		// Foreach shard.
 		for _, shard := range m {
@@ -206,3 +235,19 @@ func (m ConcurrentMap) IterBuffered() <-chan Tuple {
 	}()
 	return ch
 }
+
+// isSafe reports whether the shards of the map are concurrent-safe.
+func (m ConcurrentMap) isSafe() bool {
+	return len(m) > 0 && m[0].IsSafe()
+}
+
+// tuples returns all key, value pairs of a map which is not concurrent-safe.
+func (m ConcurrentMap) tuples() []Tuple {
+	tuples := make([]Tuple, 0, m.Count())
+	for _, shard := range m {
+		for key, val := range shard.items {
+			tuples = append(tuples, Tuple{key, val})
+		}
+	}
+	return tuples
+}
@@ -4,6 +4,8 @@ import (
 	"sort"
 	"strconv"
 	"testing"
+
+	"github.com/funbytes/modern-go/container"
 )
 
 type Animal struct {
@@ -228,3 +230,40 @@ func TestConcurrent(t *testing.T) {
 		}
 	}
 }
+
+func TestUnsafeMap(t *testing.T) {
+	m := New(false)
+	if m[0].IsSafe() || !New()[0].IsSafe() {
+		t.Error("New(false) should make unsafe shards, New() safe ones.")
+	}
+	m.Set("elephant", Animal{"elephant"})
+	if m.Len() != 1 || m.IsEmpty() {
+		t.Error("map should contain exactly one element.")
+	}
+	m.Clear()
+	if !m.IsEmpty() {
+		t.Error("map should be empty after Clear.")
+	}
+	var _ container.Container = m
+}
+
+func TestUnsafeMapIterWhileWriting(t *testing.T) {
+	m := New(false)
+	for i := 0; i < 100; i++ {
+		m.Set(KType(strconv.Itoa(i)), Animal{strconv.Itoa(i)})
+	}
+	for name, iter := range map[string]func() <-chan Tuple{"Iter": m.Iter, "IterBuffered": m.IterBuffered} {
+		counter := 0
+		for item := range iter() {
+			m.Set(item.Key+"+", item.Val)
+			counter++
+		}
+		if counter != 100 {
+			t.Errorf("%s should visit the 100 elements present when called, visited %d", name, counter)
+		}
+		m.Clear()
+		for i := 0; i < 100; i++ {
+			m.Set(KType(strconv.Itoa(i)), Animal{strconv.Itoa(i)})
+		}
+	}
+}
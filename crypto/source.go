@@ -0,0 +1,30 @@
+package crypto
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+)
+
+// cryptoSource is a rand.Source64 reading from crypto/rand.
+type cryptoSource struct{}
+
+// NewRandSource returns a math/rand source backed by crypto/rand, for draws
+// that must not be predictable. It is safe for concurrent use and ignores Seed.
+func NewRandSource() rand.Source64 {
+	return cryptoSource{}
+}
+
+func (cryptoSource) Seed(int64) {}
+
+func (s cryptoSource) Int63() int64 {
+	return int64(s.Uint64() & (1<<63 - 1))
+}
+
+func (cryptoSource) Uint64() uint64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return binary.LittleEndian.Uint64(b[:])
+}
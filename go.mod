@@ -1,6 +1,6 @@
 module github.com/funbytes/modern-go
 
-go 1.13
+go 1.18
 
 require (
 	github.com/smartystreets/goconvey v1.6.4
@@ -8,3 +8,12 @@ require (
 	golang.org/x/crypto v0.0.0-20210513164829-c07d793c2f9a
 	golang.org/x/sys v0.0.0-20210608053332-aa57babbf139
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/gopherjs/gopherjs v0.0.0-20181017120253-0766667cb4d1 // indirect
+	github.com/jtolds/gls v4.20.0+incompatible // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/smartystreets/assertions v0.0.0-20180927180507-b2de0cb4f26d // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
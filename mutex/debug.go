@@ -0,0 +1,389 @@
+package mutex
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultHoldThreshold is the hold threshold of DebugOptions left zero.
+const DefaultHoldThreshold = time.Second
+
+// DebugOptions configures the debug mode, see EnableDebug.
+type DebugOptions struct {
+	// HoldThreshold is how long a lock may be held before it is reported,
+	// DefaultHoldThreshold if zero. A negative value disables the report.
+	HoldThreshold time.Duration
+	// Report is called for each problem found, from the goroutine which
+	// found it. It defaults to printing the report with package log.
+	Report func(r *Report)
+}
+
+// ReportKind tells which problem a Report is about.
+type ReportKind int
+
+const (
+	// ReportLongHold is a lock held longer than the hold threshold. It is
+	// reported while the lock is still held, so a lock which is never
+	// released is reported too.
+	ReportLongHold ReportKind = iota
+	// ReportInversion is a pair of locks acquired in both orders, which
+	// deadlocks once two goroutines do so at the same time.
+	ReportInversion
+)
+
+// String returns the name of the kind.
+func (k ReportKind) String() string {
+	switch k {
+	case ReportLongHold:
+		return "long hold"
+	case ReportInversion:
+		return "lock order inversion"
+	}
+	return "ReportKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Report is a problem found by the debug mode.
+type Report struct {
+	Kind ReportKind
+	// Lock is the name of the lock, see SetName.
+	Lock string
+	// Goroutine is the id of the goroutine holding Lock for ReportLongHold,
+	// or acquiring it while holding Other for ReportInversion.
+	Goroutine int64
+	// Stack is the stack where Goroutine acquired Lock.
+	Stack string
+	// Held is how long Lock has been held, for ReportLongHold.
+	Held time.Duration
+	// Other is the name of the lock acquired in the opposite order, and
+	// OtherStack the stack where Other was first acquired while holding
+	// Lock, for ReportInversion.
+	Other      string
+	OtherStack string
+}
+
+// String formats the report for logging.
+func (r *Report) String() string {
+	var b strings.Builder
+	switch r.Kind {
+	case ReportLongHold:
+		fmt.Fprintf(&b, "mutex: %s held for %v by goroutine %d, acquired at:\n%s",
+			r.Lock, r.Held, r.Goroutine, r.Stack)
+	case ReportInversion:
+		fmt.Fprintf(&b, "mutex: %s acquired while holding %s by goroutine %d at:\n%s\n"+
+			"but %s was acquired while holding %s at:\n%s",
+			r.Lock, r.Other, r.Goroutine, r.Stack, r.Other, r.Lock, r.OtherStack)
+	default:
+		fmt.Fprintf(&b, "mutex: %v on %s", r.Kind, r.Lock)
+	}
+	return b.String()
+}
+
+// debugOn is 1 while the debug mode is enabled, so that constructors check
+// it without locking.
+var debugOn int32
+
+// debug is the state of the debug mode, shared by all tracked locks.
+var debug struct {
+	sync.Mutex
+	opts DebugOptions
+	// held lists the tracked locks held by each goroutine, in acquisition
+	// order.
+	held map[int64][]*tracker
+	// order maps each pair of tracked locks acquired in this order by one
+	// goroutine to the stack where it was first seen.
+	order    map[[2]*tracker]string
+	reported map[[2]*tracker]bool
+}
+
+// EnableDebug turns on the debug mode for the locks in concurrent-safe usage
+// created from then on, by this package or by the containers of this module.
+// A tracked lock records the goroutine and the stack acquiring it, reports it
+// when it is held longer than the hold threshold, and reports each pair of
+// tracked locks acquired in both orders, which may deadlock. Only direct
+// inversions between two locks are detected, not longer cycles.
+//
+// Tracking captures a stack on every lock, and remembers every pair of locks
+// ever nested, so it is meant for tests and staging rather than production.
+// Building with the mutexdebug tag enables it with the default options.
+func EnableDebug(opts DebugOptions) {
+	if opts.HoldThreshold == 0 {
+		opts.HoldThreshold = DefaultHoldThreshold
+	}
+	if opts.Report == nil {
+		opts.Report = func(r *Report) { log.Print(r) }
+	}
+	debug.Lock()
+	defer debug.Unlock()
+	debug.opts = opts
+	if debug.held == nil {
+		debug.held = make(map[int64][]*tracker)
+		debug.order = make(map[[2]*tracker]string)
+		debug.reported = make(map[[2]*tracker]bool)
+	}
+	atomic.StoreInt32(&debugOn, 1)
+}
+
+// DisableDebug turns off the debug mode for the locks created from then on.
+// Locks created while it was enabled keep being tracked.
+func DisableDebug() {
+	atomic.StoreInt32(&debugOn, 0)
+}
+
+// DebugEnabled reports whether the locks created now are tracked.
+func DebugEnabled() bool {
+	return atomic.LoadInt32(&debugOn) == 1
+}
+
+// tracker records the holders of a lock in debug mode.
+type tracker struct {
+	mu      sync.Mutex
+	name    string
+	holders []*holding
+}
+
+// holding is an acquisition of a tracked lock, until it is released.
+type holding struct {
+	gid   int64
+	write bool
+	since time.Time
+	stack string
+	timer *time.Timer
+}
+
+// newTracker returns a tracker if the debug mode is enabled, and nil
+// otherwise. It names the lock after the site creating it.
+func newTracker() *tracker {
+	if !DebugEnabled() {
+		return nil
+	}
+	return &tracker{name: creator()}
+}
+
+// setName renames t, which may be nil.
+func (t *tracker) setName(name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.name = name
+	t.mu.Unlock()
+}
+
+func (t *tracker) getName() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.name
+}
+
+// acquire checks the lock order, then calls lock and records the holding.
+func (t *tracker) acquire(write bool, lock func()) {
+	gid, stack := goroutine()
+	t.checkOrder(gid, stack)
+	lock()
+	t.acquired(gid, stack, write)
+}
+
+// tryAcquire calls try and records the holding if it succeeds. A try-lock
+// never waits, so it cannot deadlock and its order is not checked.
+func (t *tracker) tryAcquire(write bool, try func() bool) bool {
+	if !try() {
+		return false
+	}
+	gid, stack := goroutine()
+	t.acquired(gid, stack, write)
+	return true
+}
+
+// checkOrder records the order of t after each lock held by goroutine gid,
+// and reports the pairs already seen in the opposite order.
+func (t *tracker) checkOrder(gid int64, stack string) {
+	var reports []*Report
+	debug.Lock()
+	report := debug.opts.Report
+	for _, h := range debug.held[gid] {
+		if h == t {
+			continue
+		}
+		pair := [2]*tracker{h, t}
+		if _, ok := debug.order[pair]; !ok {
+			debug.order[pair] = stack
+		}
+		other, ok := debug.order[[2]*tracker{t, h}]
+		if ok && !debug.reported[pair] {
+			debug.reported[pair] = true
+			debug.reported[[2]*tracker{t, h}] = true
+			reports = append(reports, &Report{
+				Kind:       ReportInversion,
+				Lock:       t.getName(),
+				Goroutine:  gid,
+				Stack:      stack,
+				Other:      h.getName(),
+				OtherStack: other,
+			})
+		}
+	}
+	debug.Unlock()
+	for _, r := range reports {
+		report(r)
+	}
+}
+
+// acquired records that goroutine gid holds t, and arms the long hold report.
+func (t *tracker) acquired(gid int64, stack string, write bool) {
+	h := &holding{gid: gid, write: write, since: time.Now(), stack: stack}
+	debug.Lock()
+	debug.held[gid] = append(debug.held[gid], t)
+	opts := debug.opts
+	debug.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if opts.HoldThreshold > 0 {
+		h.timer = time.AfterFunc(opts.HoldThreshold, func() {
+			opts.Report(&Report{
+				Kind:      ReportLongHold,
+				Lock:      t.getName(),
+				Goroutine: h.gid,
+				Stack:     h.stack,
+				Held:      time.Since(h.since),
+			})
+		})
+	}
+	t.holders = append(t.holders, h)
+}
+
+// release forgets a holding of t, preferring the one of the calling
+// goroutine since a lock may be released by another goroutine.
+func (t *tracker) release(write bool) {
+	gid, _ := goroutine()
+	t.mu.Lock()
+	i := -1
+	for j, h := range t.holders {
+		if h.write == write && (i < 0 || h.gid == gid) {
+			i = j
+		}
+	}
+	if i < 0 {
+		t.mu.Unlock()
+		return
+	}
+	h := t.holders[i]
+	t.holders = append(t.holders[:i], t.holders[i+1:]...)
+	t.mu.Unlock()
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+
+	debug.Lock()
+	defer debug.Unlock()
+	held := debug.held[h.gid]
+	for j := len(held) - 1; j >= 0; j-- {
+		if held[j] == t {
+			held = append(held[:j], held[j+1:]...)
+			break
+		}
+	}
+	if len(held) == 0 {
+		delete(debug.held, h.gid)
+	} else {
+		debug.held[h.gid] = held
+	}
+}
+
+// goroutine returns the id and the stack of the calling goroutine, without
+// the frames of this package.
+func goroutine() (int64, string) {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	// The stack starts with "goroutine 123 [running]:".
+	line := buf
+	if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+		line, buf = buf[:i], buf[i+1:]
+	}
+	fields := bytes.Fields(line)
+	var gid int64
+	if len(fields) > 1 {
+		gid, _ = strconv.ParseInt(string(fields[1]), 10, 64)
+	}
+	// Each frame is a function line followed by a file line.
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	for len(lines) >= 2 && ownFrame(lines[0]) {
+		lines = lines[2:]
+	}
+	return gid, strings.Join(lines, "\n")
+}
+
+// ownPackages are the packages whose frames are left out of stacks and lock
+// names.
+var ownPackages = []string{
+	"github.com/funbytes/modern-go/mutex.",
+	"github.com/funbytes/modern-go/internal/rwmutex.",
+}
+
+func ownFrame(function string) bool {
+	for _, p := range ownPackages {
+		if strings.HasPrefix(function, p) && !strings.HasPrefix(function, p+"Test") {
+			return true
+		}
+	}
+	return false
+}
+
+// creator names a lock after the function creating it and the site calling
+// that function from another package, such as
+// "carray.NewArraySize from main.go:12".
+func creator() string {
+	pc := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pc[:runtime.Callers(2, pc)])
+	var fn, pkg string
+	for {
+		f, more := frames.Next()
+		switch {
+		case ownFrame(f.Function):
+		case fn == "":
+			fn, pkg = f.Function, funcPackage(f.Function)
+		case funcPackage(f.Function) != pkg:
+			return shortFunc(fn) + " from " + shortFile(f.File) + ":" + strconv.Itoa(f.Line)
+		}
+		if !more {
+			return shortFunc(fn)
+		}
+	}
+}
+
+// funcPackage returns the package path of a function name as reported by
+// runtime.Frame.
+func funcPackage(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+	if i := strings.IndexByte(function[slash+1:], '.'); i >= 0 {
+		return function[:slash+1+i]
+	}
+	return function
+}
+
+func shortFunc(function string) string {
+	return function[strings.LastIndexByte(function, '/')+1:]
+}
+
+func shortFile(file string) string {
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+			return file[j+1:]
+		}
+	}
+	return file
+}
@@ -0,0 +1,8 @@
+//go:build mutexdebug
+// +build mutexdebug
+
+package mutex
+
+func init() {
+	EnableDebug(DebugOptions{})
+}
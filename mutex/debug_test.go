@@ -0,0 +1,145 @@
+package mutex
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// enableDebug enables the debug mode until the end of the test, and returns
+// the channel receiving the reports.
+func enableDebug(t *testing.T, threshold time.Duration) <-chan *Report {
+	reports := make(chan *Report, 16)
+	EnableDebug(DebugOptions{
+		HoldThreshold: threshold,
+		Report:        func(r *Report) { reports <- r },
+	})
+	t.Cleanup(DisableDebug)
+	return reports
+}
+
+func nextReport(t *testing.T, reports <-chan *Report) *Report {
+	t.Helper()
+	select {
+	case r := <-reports:
+		return r
+	case <-time.After(time.Second):
+		t.Fatal("no report")
+		return nil
+	}
+}
+
+func noReport(t *testing.T, reports <-chan *Report) {
+	t.Helper()
+	select {
+	case r := <-reports:
+		t.Fatalf("unexpected report: %v", r)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDebugSwitch(t *testing.T) {
+	DisableDebug()
+	if NewRWMutex(true).dbg != nil {
+		t.Fatal("lock tracked with the debug mode disabled")
+	}
+	enableDebug(t, -1)
+	if NewRWMutex().dbg != nil || NewMutex().dbg != nil {
+		t.Fatal("unsafe lock tracked")
+	}
+	mu := NewRWMutex(true)
+	if mu.dbg == nil || NewMutex(true).dbg == nil {
+		t.Fatal("lock not tracked with the debug mode enabled")
+	}
+	if name := mu.dbg.getName(); !strings.HasPrefix(name, "mutex.TestDebugSwitch") {
+		t.Fatalf("lock named %q", name)
+	}
+	DisableDebug()
+	if NewRWMutex(true).dbg != nil {
+		t.Fatal("lock tracked after DisableDebug")
+	}
+}
+
+func TestDebugLongHold(t *testing.T) {
+	reports := enableDebug(t, 20*time.Millisecond)
+	mu := NewRWMutex(true)
+	mu.SetName("slow")
+
+	mu.Lock()
+	r := nextReport(t, reports)
+	mu.Unlock()
+	if r.Kind != ReportLongHold || r.Lock != "slow" || r.Held < 20*time.Millisecond || r.Goroutine == 0 {
+		t.Fatalf("report = %+v", r)
+	}
+	if !strings.Contains(r.Stack, "TestDebugLongHold") || strings.Contains(r.Stack, "mutex.(*RWMutex)") {
+		t.Fatalf("stack does not start at the caller:\n%s", r.Stack)
+	}
+
+	// Released locks and read locks released in time are not reported.
+	mu.RLock()
+	mu.RLock()
+	mu.RUnlock()
+	mu.RUnlock()
+	mu.Lock()
+	mu.Unlock()
+	noReport(t, reports)
+}
+
+func TestDebugInversion(t *testing.T) {
+	reports := enableDebug(t, -1)
+	a, b := NewMutex(true), NewRWMutex(true)
+	a.SetName("a")
+	b.SetName("b")
+
+	a.Lock()
+	b.RLock()
+	b.RUnlock()
+	a.Unlock()
+	noReport(t, reports)
+
+	b.Lock()
+	a.Lock()
+	a.Unlock()
+	b.Unlock()
+	r := nextReport(t, reports)
+	if r.Kind != ReportInversion || r.Lock != "a" || r.Other != "b" {
+		t.Fatalf("report = %+v", r)
+	}
+	if !strings.Contains(r.Stack, "TestDebugInversion") || !strings.Contains(r.OtherStack, "TestDebugInversion") {
+		t.Fatalf("report = %v", r)
+	}
+
+	// An inversion is reported once.
+	b.Lock()
+	a.Lock()
+	a.Unlock()
+	b.Unlock()
+	noReport(t, reports)
+}
+
+func TestDebugUnlockElsewhere(t *testing.T) {
+	enableDebug(t, -1)
+	mu := NewRWMutex(true)
+	mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		mu.Unlock()
+		close(done)
+	}()
+	<-done
+	if !mu.TryRLock() {
+		t.Fatal("TryRLock failed")
+	}
+	mu.RUnlock()
+
+	debug.Lock()
+	defer debug.Unlock()
+	if len(debug.held) != 0 {
+		t.Fatalf("held = %v", debug.held)
+	}
+	mu.dbg.mu.Lock()
+	defer mu.dbg.mu.Unlock()
+	if len(mu.dbg.holders) != 0 {
+		t.Fatalf("holders = %v", mu.dbg.holders)
+	}
+}
@@ -0,0 +1,242 @@
+// Package mutex provides sync.Mutex and sync.RWMutex with a switch for
+// concurrent safety, try-locks, context-bound locking and callback helpers.
+//
+// A lock which is not in concurrent-safe usage does nothing, so that a type
+// can offer both usages at the cost of a nil check. Locks in concurrent-safe
+// usage can be tracked by a debug mode reporting long holds and lock order
+// inversions, see EnableDebug.
+package mutex
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxBackoff bounds the sleep between two attempts of LockContext.
+const maxBackoff = time.Millisecond
+
+// Mutex is a sync.Mutex with a switch for concurrent safe feature.
+type Mutex struct {
+	mu  *sync.Mutex // nil unless in concurrent-safe usage
+	dbg *tracker    // non-nil in debug mode
+}
+
+// NewMutex creates and returns a new *Mutex, which is in concurrent-safe
+// usage if safe is given as true.
+func NewMutex(safe ...bool) *Mutex {
+	mu := Mutex{}
+	if len(safe) > 0 && safe[0] {
+		mu.mu = new(sync.Mutex)
+		mu.dbg = newTracker()
+	}
+	return &mu
+}
+
+// IsSafe checks and returns whether current mutex is in concurrent-safe usage.
+func (mu *Mutex) IsSafe() bool {
+	return mu.mu != nil
+}
+
+// Lock locks mutex.
+// It does nothing if it is not in concurrent-safe usage.
+func (mu *Mutex) Lock() {
+	if mu.dbg != nil {
+		mu.dbg.acquire(true, mu.mu.Lock)
+	} else if mu.mu != nil {
+		mu.mu.Lock()
+	}
+}
+
+// Unlock unlocks mutex.
+// It does nothing if it is not in concurrent-safe usage.
+func (mu *Mutex) Unlock() {
+	if mu.dbg != nil {
+		mu.dbg.release(true)
+	}
+	if mu.mu != nil {
+		mu.mu.Unlock()
+	}
+}
+
+// TryLock tries to lock mutex without waiting, and reports whether it
+// succeeded. It always succeeds if it is not in concurrent-safe usage.
+func (mu *Mutex) TryLock() bool {
+	if mu.dbg != nil {
+		return mu.dbg.tryAcquire(true, mu.mu.TryLock)
+	}
+	return mu.mu == nil || mu.mu.TryLock()
+}
+
+// SetName names mutex in the reports of the debug mode, see EnableDebug.
+// It does nothing if the mutex is not tracked.
+func (mu *Mutex) SetName(name string) {
+	mu.dbg.setName(name)
+}
+
+// LockContext locks mutex, waiting at most until ctx is done, in which case
+// it returns the error of ctx. Use context.WithTimeout to bound the wait.
+func (mu *Mutex) LockContext(ctx context.Context) error {
+	return lockContext(ctx, mu.TryLock)
+}
+
+// LockFunc locks mutex during the call of f.
+func (mu *Mutex) LockFunc(f func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	f()
+}
+
+// RWMutex is a sync.RWMutex with a switch for concurrent safe feature.
+type RWMutex struct {
+	mu  *sync.RWMutex // nil unless in concurrent-safe usage
+	dbg *tracker      // non-nil in debug mode
+}
+
+// NewRWMutex creates and returns a new *RWMutex, which is in concurrent-safe
+// usage if safe is given as true.
+func NewRWMutex(safe ...bool) *RWMutex {
+	mu := RWMutex{}
+	if len(safe) > 0 && safe[0] {
+		mu.mu = new(sync.RWMutex)
+		mu.dbg = newTracker()
+	}
+	return &mu
+}
+
+// IsSafe checks and returns whether current mutex is in concurrent-safe usage.
+func (mu *RWMutex) IsSafe() bool {
+	return mu.mu != nil
+}
+
+// Lock locks mutex for writing.
+// It does nothing if it is not in concurrent-safe usage.
+func (mu *RWMutex) Lock() {
+	if mu.dbg != nil {
+		mu.dbg.acquire(true, mu.mu.Lock)
+	} else if mu.mu != nil {
+		mu.mu.Lock()
+	}
+}
+
+// Unlock unlocks mutex for writing.
+// It does nothing if it is not in concurrent-safe usage.
+func (mu *RWMutex) Unlock() {
+	if mu.dbg != nil {
+		mu.dbg.release(true)
+	}
+	if mu.mu != nil {
+		mu.mu.Unlock()
+	}
+}
+
+// RLock locks mutex for reading.
+// It does nothing if it is not in concurrent-safe usage.
+func (mu *RWMutex) RLock() {
+	if mu.dbg != nil {
+		mu.dbg.acquire(false, mu.mu.RLock)
+	} else if mu.mu != nil {
+		mu.mu.RLock()
+	}
+}
+
+// RUnlock unlocks mutex for reading.
+// It does nothing if it is not in concurrent-safe usage.
+func (mu *RWMutex) RUnlock() {
+	if mu.dbg != nil {
+		mu.dbg.release(false)
+	}
+	if mu.mu != nil {
+		mu.mu.RUnlock()
+	}
+}
+
+// TryLock tries to lock mutex for writing without waiting, and reports
+// whether it succeeded. It always succeeds if it is not in concurrent-safe
+// usage.
+func (mu *RWMutex) TryLock() bool {
+	if mu.dbg != nil {
+		return mu.dbg.tryAcquire(true, mu.mu.TryLock)
+	}
+	return mu.mu == nil || mu.mu.TryLock()
+}
+
+// TryRLock tries to lock mutex for reading without waiting, and reports
+// whether it succeeded. It always succeeds if it is not in concurrent-safe
+// usage.
+func (mu *RWMutex) TryRLock() bool {
+	if mu.dbg != nil {
+		return mu.dbg.tryAcquire(false, mu.mu.TryRLock)
+	}
+	return mu.mu == nil || mu.mu.TryRLock()
+}
+
+// RLocker returns a sync.Locker which locks and unlocks mutex for reading,
+// like sync.RWMutex.RLocker.
+func (mu *RWMutex) RLocker() sync.Locker {
+	return (*rlocker)(mu)
+}
+
+type rlocker RWMutex
+
+func (r *rlocker) Lock()   { (*RWMutex)(r).RLock() }
+func (r *rlocker) Unlock() { (*RWMutex)(r).RUnlock() }
+
+// SetName names mutex in the reports of the debug mode, see EnableDebug.
+// It does nothing if the mutex is not tracked.
+func (mu *RWMutex) SetName(name string) {
+	mu.dbg.setName(name)
+}
+
+// LockContext locks mutex for writing, waiting at most until ctx is done,
+// in which case it returns the error of ctx.
+func (mu *RWMutex) LockContext(ctx context.Context) error {
+	return lockContext(ctx, mu.TryLock)
+}
+
+// RLockContext locks mutex for reading, waiting at most until ctx is done,
+// in which case it returns the error of ctx.
+func (mu *RWMutex) RLockContext(ctx context.Context) error {
+	return lockContext(ctx, mu.TryRLock)
+}
+
+// LockFunc locks mutex for writing during the call of f.
+func (mu *RWMutex) LockFunc(f func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	f()
+}
+
+// RLockFunc locks mutex for reading during the call of f.
+func (mu *RWMutex) RLockFunc(f func()) {
+	mu.RLock()
+	defer mu.RUnlock()
+	f()
+}
+
+// lockContext calls try until it succeeds or ctx is done. sync locks cannot
+// be abandoned once waited on, so it polls with an exponential backoff of
+// at most maxBackoff. The waiter is thus not queued: a lock which is never
+// free for maxBackoff may be taken by goroutines calling Lock first.
+func lockContext(ctx context.Context, try func() bool) error {
+	if try() {
+		return nil
+	}
+	backoff := time.Microsecond
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if try() {
+			return nil
+		}
+		if backoff < maxBackoff {
+			backoff *= 2
+		}
+		timer.Reset(backoff)
+	}
+}
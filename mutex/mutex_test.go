@@ -0,0 +1,113 @@
+package mutex
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUnsafe(t *testing.T) {
+	mu, rw := NewMutex(), NewRWMutex(false)
+	if mu.IsSafe() || rw.IsSafe() {
+		t.Fatal("locks are safe without the switch")
+	}
+	// Locks which are not in concurrent-safe usage never block.
+	mu.Lock()
+	mu.Lock()
+	if !mu.TryLock() || !rw.TryLock() || !rw.TryRLock() {
+		t.Fatal("TryLock of an unsafe lock failed")
+	}
+	if err := rw.LockContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTryLock(t *testing.T) {
+	mu := NewMutex(true)
+	if !mu.TryLock() || mu.TryLock() {
+		t.Fatal("TryLock of a locked mutex succeeded")
+	}
+	mu.Unlock()
+
+	rw := NewRWMutex(true)
+	rw.RLock()
+	if !rw.TryRLock() || rw.TryLock() {
+		t.Fatal("TryLock of a read-locked mutex succeeded")
+	}
+	rw.RUnlock()
+	rw.RUnlock()
+	rw.Lock()
+	if rw.TryRLock() {
+		t.Fatal("TryRLock of a write-locked mutex succeeded")
+	}
+	rw.Unlock()
+
+	var r sync.Locker = rw.RLocker()
+	r.Lock()
+	if !rw.TryRLock() || rw.TryLock() {
+		t.Fatal("RLocker did not lock for reading")
+	}
+	rw.RUnlock()
+	r.Unlock()
+	if !rw.TryLock() {
+		t.Fatal("RLocker did not unlock")
+	}
+	rw.Unlock()
+}
+
+func TestLockContext(t *testing.T) {
+	mu := NewMutex(true)
+	mu.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mu.LockContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("LockContext of a held mutex = %v, want DeadlineExceeded", err)
+	}
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		mu.Unlock()
+	}()
+	if err := mu.LockContext(context.Background()); err != nil {
+		t.Fatalf("LockContext after Unlock = %v", err)
+	}
+
+	rw := NewRWMutex(true)
+	rw.Lock()
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := rw.RLockContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("RLockContext of a write-locked mutex = %v, want DeadlineExceeded", err)
+	}
+	rw.Unlock()
+	if err := rw.RLockContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := rw.LockContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("LockContext of a read-locked mutex = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestLockFunc(t *testing.T) {
+	rw := NewRWMutex(true)
+	mu := NewMutex(true)
+	var n int
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				rw.LockFunc(func() { n++ })
+				rw.RLockFunc(func() { _ = n })
+				mu.LockFunc(func() {})
+			}
+		}()
+	}
+	wg.Wait()
+	if n != 800 {
+		t.Fatalf("n = %d, want 800", n)
+	}
+}